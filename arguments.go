@@ -0,0 +1,172 @@
+package pgxotel
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"slices"
+	"strings"
+
+	pgx "github.com/jackc/pgx/v5"
+	attribute "go.opentelemetry.io/otel/attribute"
+)
+
+// arguments splits the leading query options (e.g. pgx.QueryExecMode) from the
+// bind parameters of a query.
+func arguments(args []any) (options []any, values []any) {
+	for index, arg := range args {
+		switch arg.(type) {
+		case pgx.QueryExecMode, pgx.QueryResultFormats, pgx.QueryResultFormatsByOID, pgx.QueryRewriter:
+			continue
+		}
+
+		return args[:index], args[index:]
+	}
+
+	return args, nil
+}
+
+func (t *QueryTracer) mode(config *pgx.ConnConfig, args []any) []attribute.KeyValue {
+	if t.Minimal {
+		return nil
+	}
+
+	name := strings.ReplaceAll(execMode(config, args).String(), " ", "_")
+	// done
+	return []attribute.KeyValue{QueryExecModeKey.String(name)}
+}
+
+// execMode returns the query exec mode of the arguments.
+func execMode(config *pgx.ConnConfig, args []any) pgx.QueryExecMode {
+	mode := config.DefaultQueryExecMode
+	// the leading options may override the default mode
+	options, _ := arguments(args)
+	for _, option := range options {
+		if value, ok := option.(pgx.QueryExecMode); ok {
+			mode = value
+		}
+	}
+
+	return mode
+}
+
+func (t *QueryTracer) parameters(args []any) []attribute.KeyValue {
+	if t.Minimal {
+		return nil
+	}
+
+	options, values := arguments(args)
+	// named arguments are rewritten into positional ones
+	for _, option := range options {
+		switch value := option.(type) {
+		case pgx.NamedArgs:
+			return t.named(value)
+		case pgx.StrictNamedArgs:
+			return t.named(value)
+		}
+	}
+
+	attrs := []attribute.KeyValue{}
+	attrs = append(attrs, ParameterCountKey.Int(len(values)))
+
+	if t.ParameterNullMask && len(values) > 0 {
+		mask := make([]byte, len(values))
+		for index, value := range values {
+			mask[index] = '0'
+			if null(value) {
+				mask[index] = '1'
+			}
+		}
+
+		attrs = append(attrs, ParameterNullMaskKey.String(string(mask)))
+	}
+
+	return attrs
+}
+
+// null reports whether the value is encoded as NULL.
+func null(value any) bool {
+	if value == nil {
+		return true
+	}
+
+	if valuer, ok := value.(driver.Valuer); ok {
+		if v := reflect.ValueOf(value); v.Kind() == reflect.Pointer && v.IsNil() {
+			return true
+		}
+
+		result, err := valuer.Value()
+		return err == nil && result == nil
+	}
+
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+// types returns the Postgres type names the positional bind parameters are encoded
+// as, or unknown when the server infers the type. Only the exec and simple
+// protocol modes encode the parameters after their Go type: the other modes
+// encode them as the types described by the server, which pgx does not expose.
+func (t *QueryTracer) types(conn *pgx.Conn, args []any) []attribute.KeyValue {
+	if !t.ParameterTypes || t.Minimal {
+		return nil
+	}
+
+	switch execMode(t.cache(conn).config, args) {
+	case pgx.QueryExecModeExec, pgx.QueryExecModeSimpleProtocol:
+	default:
+		return nil
+	}
+
+	options, values := arguments(args)
+	for _, option := range options {
+		switch option.(type) {
+		case pgx.NamedArgs, pgx.StrictNamedArgs:
+			return nil
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(values))
+	for index, value := range values {
+		names[index] = "unknown"
+		if null(value) {
+			continue
+		}
+		// the pointers are encoded as their elements
+		if v := reflect.ValueOf(value); v.Kind() == reflect.Pointer {
+			value = v.Elem().Interface()
+		}
+
+		if kind, ok := conn.TypeMap().TypeForValue(value); ok {
+			names[index] = kind.Name
+		}
+	}
+
+	return []attribute.KeyValue{ParameterTypesKey.StringSlice(names)}
+}
+
+// named returns the attributes of named arguments.
+func (t *QueryTracer) named(args map[string]any) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{}
+	attrs = append(attrs, ParameterCountKey.Int(len(args)))
+
+	if t.ParameterNames {
+		names := make([]string, 0, len(args))
+		for name := range args {
+			names = append(names, name)
+		}
+		// the map order is random
+		slices.Sort(names)
+
+		attrs = append(attrs, ParameterNamesKey.StringSlice(names))
+	}
+
+	return attrs
+}
@@ -0,0 +1,78 @@
+package pgxotel
+
+import (
+	"context"
+
+	pgx "github.com/jackc/pgx/v5"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+// BatchSender is the interface implemented by pgx.Conn, pgx.Tx and pgxpool.Pool.
+type BatchSender interface {
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// Batch is a pgx.Batch that records the span of the caller that queued each query.
+// The spans of the queries sent by SendBatch are linked to them.
+type Batch struct {
+	pgx.Batch
+	links []trace.Link
+}
+
+// Queue queues the query like pgx.Batch.Queue and records the span of ctx.
+func (b *Batch) Queue(ctx context.Context, query string, args ...any) *pgx.QueuedQuery {
+	b.links = append(b.links, trace.LinkFromContext(ctx))
+	// queue the query
+	return b.Batch.Queue(query, args...)
+}
+
+// enqueue tracks the links of the queries of a batch.
+type enqueue struct {
+	// links are the spans that queued the queries
+	links []trace.Link
+	// index is the index of the next query
+	index int
+}
+
+type enqueueKey struct{}
+
+// SendBatch sends the batch on s. The span of every query of the batch is a child of
+// the batch span and is linked to the span that queued the query.
+func SendBatch(ctx context.Context, s BatchSender, b *Batch) pgx.BatchResults {
+	ctx = context.WithValue(ctx, enqueueKey{}, &enqueue{links: b.links})
+	// send the batch
+	return s.SendBatch(ctx, &b.Batch)
+}
+
+type rootKey struct{}
+
+// ContextWithRootSpan returns a copy of ctx that carries the root span of the
+// request (e.g. the server span of an HTTP handler), which the spans of the batch
+// queries are linked to when BatchRootLink is set.
+func ContextWithRootSpan(ctx context.Context, sc trace.SpanContext) context.Context {
+	return context.WithValue(ctx, rootKey{}, sc)
+}
+
+// rootFrom returns the link to the root span of the request, if any.
+func rootFrom(ctx context.Context) (trace.Link, bool) {
+	sc, ok := ctx.Value(rootKey{}).(trace.SpanContext)
+	if !ok || !sc.IsValid() {
+		return trace.Link{}, false
+	}
+
+	return trace.Link{SpanContext: sc}, true
+}
+
+// enqueued returns the link to the span that queued the next query of the batch,
+// if any.
+func enqueued(ctx context.Context) (trace.Link, bool) {
+	e, _ := ctx.Value(enqueueKey{}).(*enqueue)
+	if e == nil || e.index >= len(e.links) {
+		return trace.Link{}, false
+	}
+
+	link := e.links[e.index]
+	e.index++
+	// the query might have been queued without a span
+	return link, link.SpanContext.IsValid()
+}
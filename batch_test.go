@@ -0,0 +1,74 @@
+package pgxotel_test
+
+import (
+	"context"
+	"os"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pgx-contrib/pgxotel"
+	"go.opentelemetry.io/otel"
+)
+
+func ExampleSendBatch() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	config.ConnConfig.Tracer = &pgxotel.QueryTracer{
+		Name: "example-api",
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the pool
+	defer pool.Close()
+
+	batch := &pgxotel.Batch{}
+	batch.Queue(context.TODO(), "UPDATE customer SET visits = visits + 1 WHERE id = $1", 1)
+	batch.Queue(context.TODO(), "UPDATE customer SET visits = visits + 1 WHERE id = $1", 2)
+
+	results := pgxotel.SendBatch(context.TODO(), pool, batch)
+	// close the results
+	defer results.Close()
+
+	for range 2 {
+		if _, err := results.Exec(); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func ExampleContextWithRootSpan() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	config.ConnConfig.Tracer = pgxotel.NewQueryTracer("example-api",
+		pgxotel.WithBatchRootLink(),
+	)
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the pool
+	defer pool.Close()
+
+	// the span of the request, e.g. started by the HTTP middleware
+	ctx, span := otel.Tracer("example-api").Start(context.TODO(), "GET /customers")
+	defer span.End()
+
+	ctx = pgxotel.ContextWithRootSpan(ctx, span.SpanContext())
+
+	batch := &pgx.Batch{}
+	batch.Queue("UPDATE customer SET visits = visits + 1 WHERE id = $1", 1)
+
+	if err := pool.SendBatch(ctx, batch).Close(); err != nil {
+		panic(err)
+	}
+}
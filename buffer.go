@@ -0,0 +1,36 @@
+package pgxotel
+
+import (
+	"sync"
+
+	attribute "go.opentelemetry.io/otel/attribute"
+)
+
+// buffers pools the attribute slices assembled by the tracer hooks.
+var buffers = sync.Pool{
+	New: func() any {
+		return &buffer{attrs: make([]attribute.KeyValue, 0, 16)}
+	},
+}
+
+// buffer is a reusable attribute slice.
+type buffer struct {
+	attrs []attribute.KeyValue
+}
+
+func newBuffer() *buffer {
+	return buffers.Get().(*buffer)
+}
+
+// free returns the attributes to the pool. The span copies the attributes
+// passed to Start and SetAttributes, so they can be reused afterwards.
+func (b *buffer) free(attrs []attribute.KeyValue) {
+	// do not hold on to oversized slices
+	if cap(attrs) > 64 {
+		return
+	}
+
+	clear(attrs)
+	b.attrs = attrs[:0]
+	buffers.Put(b)
+}
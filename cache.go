@@ -0,0 +1,80 @@
+package pgxotel
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	attribute "go.opentelemetry.io/otel/attribute"
+)
+
+// cacheKey is the prefix of the connection custom data key of the connection
+// cache.
+const cacheKey = "pgxotel.cache"
+
+// tracers numbers the tracers, which keep their state of a connection under their
+// own custom data keys.
+var tracers atomic.Uint64
+
+// dataKeys are the connection custom data keys of a tracer.
+type dataKeys struct {
+	cache          string
+	statementCache string
+}
+
+// keys returns the connection custom data keys of the tracer, since several
+// tracers might share the connection (e.g. through a multi tracer).
+func (t *QueryTracer) keys() *dataKeys {
+	t.keysOnce.Do(func() {
+		id := strconv.FormatUint(tracers.Add(1), 10)
+		t.dataKeys = dataKeys{
+			cache:          cacheKey + "." + id,
+			statementCache: statementCacheKey + "." + id,
+		}
+	})
+
+	return &t.dataKeys
+}
+
+// connCache caches the values derived from the config of a connection, since
+// conn.Config copies the config on every call.
+type connCache struct {
+	// config is a copy of the connection config
+	config *pgx.ConnConfig
+	// attrs are the attributes derived from the config
+	attrs []attribute.KeyValue
+	// opened is the time the connection was established
+	opened time.Time
+	// statements is the number of statements executed over the connection
+	statements int64
+}
+
+// cache returns the cache of the connection.
+func (t *QueryTracer) cache(conn *pgx.Conn) *connCache {
+	data := conn.PgConn().CustomData()
+	key := t.keys().cache
+	if cache, ok := data[key].(*connCache); ok {
+		return cache
+	}
+
+	config := conn.Config()
+
+	cache := &connCache{
+		config: config,
+		attrs:  t.config(config),
+		opened: t.clock().Now(),
+	}
+	// the backend process is the join key for the server logs
+	if pid := conn.PgConn().PID(); pid != 0 {
+		cache.attrs = append(cache.attrs, BackendPIDKey.Int64(int64(pid)))
+	}
+
+	if t.ServerVersion {
+		cache.attrs = append(cache.attrs, t.serverVersion(conn.PgConn())...)
+	}
+
+	data[key] = cache
+	// done!
+	return cache
+}
@@ -0,0 +1,56 @@
+package pgxotel
+
+import (
+	"runtime"
+	"strings"
+
+	attribute "go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+// callerDepth is the maximum number of frames walked to find the call site.
+const callerDepth = 32
+
+// caller returns the code attributes of the first frame outside of pgx, pgxotel
+// and the runtime.
+func (t *QueryTracer) caller() []attribute.KeyValue {
+	if !t.CallerAttributes {
+		return nil
+	}
+
+	var pcs [callerDepth]uintptr
+	// skip runtime.Callers and caller
+	count := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:count])
+
+	for {
+		frame, more := frames.Next()
+		if !internal(frame.Function) {
+			return []attribute.KeyValue{
+				semconv.CodeFunction(frame.Function),
+				semconv.CodeFilepath(frame.File),
+				semconv.CodeLineNumber(frame.Line),
+			}
+		}
+
+		if !more {
+			return nil
+		}
+	}
+}
+
+// internal reports whether the function belongs to pgx, pgxotel or the runtime.
+func internal(function string) bool {
+	for _, prefix := range []string{
+		"github.com/jackc/pgx/",
+		"github.com/pgx-contrib/pgxotel.",
+		"github.com/pgx-contrib/pgxotel/",
+		"runtime.",
+	} {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,73 @@
+package pgxotel
+
+import (
+	"time"
+)
+
+// Clock tells the time and schedules the timers of a QueryTracer: the durations of
+// the deferred spans, the slow query diagnostics and the heartbeats of the
+// collectors. It can be replaced in tests (see pgxoteltest.Clock).
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// AfterFunc calls fn in its own goroutine after the duration.
+	AfterFunc(d time.Duration, fn func()) Timer
+	// NewTicker returns a ticker that ticks every period.
+	NewTicker(period time.Duration) Ticker
+}
+
+// Timer is a timer scheduled by a Clock.
+type Timer interface {
+	// Stop prevents the timer from firing. It reports whether the timer was
+	// stopped before it fired.
+	Stop() bool
+}
+
+// Ticker is a ticker created by a Clock.
+type Ticker interface {
+	// C returns the channel of the ticks.
+	C() <-chan time.Time
+	// Stop turns the ticker off.
+	Stop()
+}
+
+// systemClock is the Clock of the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, fn func()) Timer {
+	return time.AfterFunc(d, fn)
+}
+
+func (systemClock) NewTicker(period time.Duration) Ticker {
+	return systemTicker{ticker: time.NewTicker(period)}
+}
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t systemTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// clock returns the Clock of the tracer.
+func (t *QueryTracer) clock() Clock {
+	if t.Clock != nil {
+		return t.Clock
+	}
+
+	return systemClock{}
+}
+
+// since returns the time elapsed since start according to the Clock.
+func (t *QueryTracer) since(start time.Time) time.Duration {
+	return t.clock().Now().Sub(start)
+}
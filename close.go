@@ -0,0 +1,98 @@
+package pgxotel
+
+import (
+	"context"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	attribute "go.opentelemetry.io/otel/attribute"
+	metric "go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+const (
+	// ConnectionLifetimeKey is the attribute key for the time in seconds a
+	// connection was open.
+	ConnectionLifetimeKey = attribute.Key("db.postgresql.connection.lifetime")
+	// ConnectionStatementsKey is the attribute key for the number of statements
+	// executed over a connection.
+	ConnectionStatementsKey = attribute.Key("db.postgresql.connection.statements")
+	// CloseReasonKey is the attribute key for the reason (graceful or error) a
+	// connection was closed.
+	CloseReasonKey = attribute.Key("db.postgresql.connection.close_reason")
+)
+
+// BeforeClose records the closing of the connection. It only creates a Close span
+// with RootSpans, since the pools close the connections in the background. It can
+// be used as pgxpool.Config.BeforeClose.
+func (t *QueryTracer) BeforeClose(conn *pgx.Conn) {
+	t.close(context.Background(), conn, t.RootSpans, func(context.Context) error { return nil })
+}
+
+// Close closes the connection, traced by a Close span.
+func (t *QueryTracer) Close(ctx context.Context, conn *pgx.Conn) error {
+	return t.close(ctx, conn, true, conn.Close)
+}
+
+func (t *QueryTracer) close(ctx context.Context, conn *pgx.Conn, traced bool, fn func(context.Context) error) error {
+	// the connection is closed already when it broke
+	reason := "graceful"
+	if conn.IsClosed() {
+		reason = "error"
+	}
+
+	cache := t.cache(conn)
+	lifetime := t.since(cache.opened)
+
+	t.recordClose(ctx, cache, reason, lifetime)
+	t.clearStatementCache(ctx, conn)
+
+	if !traced || t.DisableSpans || t.disabled(OperationClose) {
+		return fn(ctx)
+	}
+
+	buffer := newBuffer()
+	attrs := buffer.attrs
+	attrs = append(attrs, cache.attrs...)
+	attrs = append(attrs, t.instance(conn)...)
+	// prepare the span
+	ctx, span := t.start(ctx, "Close", attrs, t.SpanStartOptions[OperationClose]...)
+	buffer.free(attrs)
+
+	err := fn(ctx)
+
+	attrs = []attribute.KeyValue{}
+	attrs = append(attrs, CloseReasonKey.String(reason))
+	attrs = append(attrs, ConnectionLifetimeKey.Float64(lifetime.Seconds()))
+	attrs = append(attrs, ConnectionStatementsKey.Int64(cache.statements))
+	// done!
+	t.stop(ctx, span, pgconn.CommandTag{}, err, attrs)
+	return err
+}
+
+// recordClose records the lifetime of the connection that is closed.
+func (t *QueryTracer) recordClose(ctx context.Context, cache *connCache, reason string, lifetime time.Duration) {
+	if !t.Metrics {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBName(cache.config.Database),
+	}
+	attrs = stabilize(attrs)
+	attrs = append(attrs, CloseReasonKey.String(reason))
+	attrs = append(attrs, t.poolName()...)
+	t.instruments().lifetime.Record(ctx, lifetime.Seconds(), metric.WithAttributes(attrs...))
+}
+
+// countStatement counts the statement executed over the connection.
+func (t *QueryTracer) countStatement(conn *pgx.Conn) {
+	t.cache(conn).statements++
+}
+
+// opened marks the time the connection was established.
+func (t *QueryTracer) opened(conn *pgx.Conn) {
+	t.cache(conn).opened = t.clock().Now()
+}
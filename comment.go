@@ -0,0 +1,118 @@
+package pgxotel
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+
+	pgx "github.com/jackc/pgx/v5"
+	propagation "go.opentelemetry.io/otel/propagation"
+)
+
+// CommentTags is a propagator that injects the tags returned by the function into
+// the SQL comments of Commenter, for the custom comment keys (e.g. route or
+// application). It does not extract anything.
+type CommentTags func(ctx context.Context) map[string]string
+
+// Inject implements propagation.TextMapPropagator.
+func (f CommentTags) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	for key, value := range f(ctx) {
+		carrier.Set(key, value)
+	}
+}
+
+// Extract implements propagation.TextMapPropagator.
+func (f CommentTags) Extract(ctx context.Context, _ propagation.TextMapCarrier) context.Context {
+	return ctx
+}
+
+// Fields implements propagation.TextMapPropagator.
+func (f CommentTags) Fields() []string {
+	return nil
+}
+
+// Commenter returns a pgx.QueryRewriter that appends the sqlcommenter comment of
+// ctx (see Comment) to the statement. It is passed as the first argument of a
+// query.
+//
+// WARNING: the statements with a comment are unique, so that every commented
+// query prepares a new statement and fills the statement cache of pgx with
+// statements that are never reused. Pass pgx.QueryExecModeExec (or
+// pgx.QueryExecModeDescribeExec) along with the Commenter, or set it as the
+// DefaultQueryExecMode of the connections:
+//
+//	conn.Query(ctx, sql, pgx.QueryExecModeExec, tracer.Commenter(), args...)
+//
+// pgx only applies the last QueryRewriter of the arguments, so that the Commenter
+// cannot be combined with another rewriter such as pgx.NamedArgs. Append the
+// comment to the statement with Comment instead:
+//
+//	conn.Query(ctx, tracer.Comment(ctx, sql), pgx.NamedArgs{"id": id})
+func (t *QueryTracer) Commenter() pgx.QueryRewriter {
+	return commenter{tracer: t}
+}
+
+type commenter struct {
+	tracer *QueryTracer
+}
+
+// RewriteQuery implements pgx.QueryRewriter.
+func (c commenter) RewriteQuery(ctx context.Context, _ *pgx.Conn, sql string, args []any) (string, []any, error) {
+	return c.tracer.Comment(ctx, sql), args, nil
+}
+
+// Comment appends the sqlcommenter comment of ctx to the statement: the keys
+// injected by the CommentPropagator (W3C traceparent by default), restricted to
+// the CommentKeys, in the key='value' format of sqlcommenter.
+func (t *QueryTracer) Comment(ctx context.Context, sql string) string {
+	var propagator propagation.TextMapPropagator = propagation.TraceContext{}
+	if t.CommentPropagator != nil {
+		propagator = t.CommentPropagator
+	}
+
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+
+	keys := make([]string, 0, len(carrier))
+	for key := range carrier {
+		if len(t.CommentKeys) == 0 || slices.Contains(t.CommentKeys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return sql
+	}
+	// the keys are sorted
+	slices.Sort(keys)
+
+	builder := &strings.Builder{}
+	for index, key := range keys {
+		if index > 0 {
+			builder.WriteByte(',')
+		}
+
+		builder.WriteString(commentEscape(key))
+		builder.WriteString("='")
+		builder.WriteString(commentEscape(carrier[key]))
+		builder.WriteByte('\'')
+	}
+
+	comment := "/*" + builder.String() + "*/"
+	// the comment precedes the terminating semicolon
+	query := strings.TrimRightFunc(sql, unicode.IsSpace)
+	if rest, ok := strings.CutSuffix(query, ";"); ok {
+		return rest + " " + comment + ";"
+	}
+
+	return query + " " + comment
+}
+
+// commentEscape encodes the key or value of a sqlcommenter comment: it is
+// percent-encoded (spaces as %20 and slashes as %2F), including the quotes and
+// the comment delimiters.
+func commentEscape(value string) string {
+	return url.PathEscape(value)
+}
@@ -0,0 +1,22 @@
+package pgxotel_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pgx-contrib/pgxotel"
+)
+
+func ExampleQueryTracer_Comment() {
+	tracer := pgxotel.NewQueryTracer("example-api",
+		pgxotel.WithCommentPropagator(pgxotel.CommentTags(func(ctx context.Context) map[string]string {
+			return map[string]string{
+				"application": "example api",
+				"route":       "/customers/{id}",
+			}
+		})),
+	)
+
+	fmt.Println(tracer.Comment(context.TODO(), "SELECT * FROM customer WHERE id = $1;"))
+	// Output: SELECT * FROM customer WHERE id = $1 /*application='example%20api',route='%2Fcustomers%2F%7Bid%7D'*/;
+}
@@ -0,0 +1,92 @@
+package pgxotel
+
+import (
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	attribute "go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	stable "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	// RowsAffectedKey is the attribute key for the number of rows affected by an
+	// operation, as recorded by otelpgx.
+	RowsAffectedKey = attribute.Key("pgx.rows_affected")
+	// SQLStateKey is the attribute key for the SQLSTATE code of an error, as recorded
+	// by otelpgx.
+	SQLStateKey = attribute.Key("pgx.sql_state")
+	// PrepareNameKey is the attribute key for the name of a prepared statement, as
+	// recorded by otelpgx.
+	PrepareNameKey = attribute.Key("pgx.prepare_stmt.name")
+)
+
+// otelpgxKeys maps the attribute keys to the keys recorded by otelpgx.
+var otelpgxKeys = map[attribute.Key]attribute.Key{
+	semconv.DBSystemKey:        attribute.Key("db.system.name"),
+	semconv.DBNameKey:          stable.DBNamespaceKey,
+	semconv.DBStatementKey:     stable.DBQueryTextKey,
+	semconv.DBOperationKey:     stable.DBOperationNameKey,
+	semconv.DBSQLTableKey:      stable.DBCollectionNameKey,
+	semconv.DBUserKey:          attribute.Key("user.name"),
+	semconv.NetSockPeerAddrKey: stable.ServerAddressKey,
+	semconv.NetSockPeerPortKey: stable.ServerPortKey,
+}
+
+// compatible renames the attributes in place to the keys recorded by otelpgx.
+func (t *QueryTracer) compatible(attrs []attribute.KeyValue) []attribute.KeyValue {
+	if !t.OtelpgxCompatible {
+		return attrs
+	}
+
+	for index, attr := range attrs {
+		if key, ok := otelpgxKeys[attr.Key]; ok {
+			attrs[index].Key = key
+		}
+	}
+
+	return attrs
+}
+
+// spanName returns the otelpgx name of the span of the operation, unless the
+// statement names the span with a hint or a comment.
+func (t *QueryTracer) spanName(kind OperationType, name string) string {
+	if !t.OtelpgxCompatible {
+		return name
+	}
+
+	switch kind {
+	case OperationConnect:
+		return "connect"
+	case OperationBatch:
+		return "batch start"
+	}
+
+	if hinted(name) {
+		return name
+	}
+
+	if _, ok := commentName(name); ok {
+		return name
+	}
+
+	return OperationFromSQL(name)
+}
+
+// keyword returns the operation attribute otelpgx records on the spans of
+// statements.
+func (t *QueryTracer) keyword(query string) []attribute.KeyValue {
+	if !t.OtelpgxCompatible || t.Minimal {
+		return nil
+	}
+
+	return []attribute.KeyValue{semconv.DBOperation(OperationFromSQL(query))}
+}
+
+// affected returns the number of affected rows otelpgx records on the spans of
+// successful operations.
+func (t *QueryTracer) affected(tag pgconn.CommandTag, err error) []attribute.KeyValue {
+	if !t.OtelpgxCompatible || err != nil {
+		return nil
+	}
+
+	return []attribute.KeyValue{RowsAffectedKey.Int64(tag.RowsAffected())}
+}
@@ -0,0 +1,87 @@
+package pgxotel
+
+import (
+	"context"
+
+	pgx "github.com/jackc/pgx/v5"
+	multitracer "github.com/jackc/pgx/v5/multitracer"
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	pgxpool "github.com/jackc/pgx/v5/pgxpool"
+)
+
+// instrumentation is the default name of the tracer.
+const instrumentation = "github.com/pgx-contrib/pgxotel"
+
+// Configure creates a QueryTracer with the given options and wires it into the
+// pool configuration: the connection tracer, the pool acquire metrics, the
+// connection hooks and the notice handler. Existing tracers and hooks are
+// preserved.
+func Configure(config *pgxpool.Config, opts ...Option) *QueryTracer {
+	t := NewQueryTracer(instrumentation, opts...)
+
+	if tracer := config.ConnConfig.Tracer; tracer != nil {
+		config.ConnConfig.Tracer = multitracer.New(tracer, t)
+	} else {
+		config.ConnConfig.Tracer = t
+	}
+
+	afterConnect := config.AfterConnect
+	config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		if afterConnect != nil {
+			if err := afterConnect(ctx, conn); err != nil {
+				return err
+			}
+		}
+
+		return t.AfterConnect(ctx, conn)
+	}
+
+	beforeAcquire := config.BeforeAcquire
+	config.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+		if beforeAcquire != nil && !beforeAcquire(ctx, conn) {
+			return false
+		}
+
+		return t.BeforeAcquire(ctx, conn)
+	}
+
+	beforeClose := config.BeforeClose
+	config.BeforeClose = func(conn *pgx.Conn) {
+		if beforeClose != nil {
+			beforeClose(conn)
+		}
+
+		t.BeforeClose(conn)
+	}
+
+	if t.ConnectPhases {
+		t.InstrumentConnect(&config.ConnConfig.Config)
+	}
+
+	onNotice := config.ConnConfig.OnNotice
+	config.ConnConfig.OnNotice = func(conn *pgconn.PgConn, notice *pgconn.Notice) {
+		if onNotice != nil {
+			onNotice(conn, notice)
+		}
+
+		t.OnNotice(conn, notice)
+	}
+
+	return t
+}
+
+// lookup returns the QueryTracer of the connection tracer, if any.
+func lookup(tracer pgx.QueryTracer) (*QueryTracer, bool) {
+	switch value := tracer.(type) {
+	case *QueryTracer:
+		return value, true
+	case *multitracer.Tracer:
+		for _, tracer := range value.QueryTracers {
+			if t, ok := lookup(tracer); ok {
+				return t, true
+			}
+		}
+	}
+
+	return nil, false
+}
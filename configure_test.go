@@ -0,0 +1,134 @@
+package pgxotel_test
+
+import (
+	"context"
+	"os"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pgx-contrib/pgxotel"
+	"github.com/pgx-contrib/pgxotel/internal/fakepg"
+	"github.com/pgx-contrib/pgxotel/pgxoteltest"
+)
+
+func ExampleConfigure() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	pgxotel.Configure(config,
+		pgxotel.WithName("example-api"),
+		pgxotel.WithMetrics(),
+		pgxotel.WithTraceIDParameter("application_name", time.Second),
+	)
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the pool
+	defer pool.Close()
+}
+
+func ExampleQueryTracer_AfterConnect() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	tracer := pgxotel.NewQueryTracer("example-api",
+		pgxotel.WithServerFactsDiscovery(),
+	)
+
+	config.ConnConfig.Tracer = tracer
+	// the server facts are queried once per connection
+	config.AfterConnect = tracer.AfterConnect
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the pool
+	defer pool.Close()
+}
+
+func TestQueryTracer_BeforeAcquire(t *testing.T) {
+	recorder := pgxoteltest.NewRecorder()
+
+	var fail atomic.Bool
+	server := &fakepg.Server{
+		Handler: func(sql string, _ []string) fakepg.Result {
+			if fail.Load() {
+				return fakepg.Result{Err: &pgconn.PgError{Code: "42501", Message: "permission denied"}}
+			}
+
+			return fakepg.Result{Columns: []string{"set_config"}}
+		},
+	}
+
+	config, err := pgxpool.ParseConfig(fakepg.ConnString + "&application_name=example-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.MaxConns = 1
+	config.ConnConfig.DialFunc = server.Dial
+	pgxotel.Configure(config, pgxotel.WithTraceIDParameter("application_name", 0), recorder.Option())
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// close the pool
+	defer pool.Close()
+
+	acquire := func(ctx context.Context) {
+		t.Helper()
+
+		conn, err := pool.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conn.Release()
+	}
+
+	ctx, span := recorder.Start(context.TODO(), "test")
+	traced := "example-api " + span.SpanContext().TraceID().String()
+	// the connection is established within the trace
+	acquire(ctx)
+	acquire(ctx)
+	// the original name is restored outside of a trace
+	acquire(context.TODO())
+	acquire(ctx)
+	// a failed update keeps the connection
+	fail.Store(true)
+	acquire(context.TODO())
+	span.End()
+
+	names := []string{}
+	for _, query := range server.Queries() {
+		if query.SQL != "SELECT set_config($1, $2, false)" || query.Args[0] != "application_name" {
+			t.Errorf("unexpected query %q", query.SQL)
+			continue
+		}
+
+		names = append(names, query.Args[1])
+	}
+
+	expected := []string{traced, "example-api", traced, "example-api"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected the application names %q, got %q", expected, names)
+	}
+	// the updates are not traced
+	pgxoteltest.AssertQueryCount(t, recorder.Spans(), 0)
+
+	if count := len(server.Startup()); count != 1 {
+		t.Errorf("expected the connection to be reused, got %d connections", count)
+	}
+}
@@ -0,0 +1,151 @@
+package pgxotel
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	pgproto3 "github.com/jackc/pgx/v5/pgproto3"
+	attribute "go.opentelemetry.io/otel/attribute"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+// AddressKey is the attribute key for the address of a connection attempt.
+const AddressKey = attribute.Key("db.postgresql.address")
+
+// InstrumentConnect wraps the LookupFunc, DialFunc, BuildFrontend and
+// ValidateConnect hooks of the config to record the phases of a connection
+// attempt as events of the Connect span: LookupStart, LookupEnd, DialStart,
+// DialEnd, TLSStart and TLSEnd (for TLS connections) and StartupEnd, which covers
+// the authentication and the exchange of the startup parameters. pgx performs the
+// TLS handshake with the first message, so it is performed ahead of the startup
+// message of the traced attempts to time it separately.
+func (t *QueryTracer) InstrumentConnect(config *pgconn.Config) {
+	hosts := &resolvedHosts{addrs: map[string][]string{}}
+
+	lookup := config.LookupFunc
+	config.LookupFunc = func(ctx context.Context, host string) ([]string, error) {
+		span := trace.SpanFromContext(ctx)
+		t.event(span, "LookupStart")
+		// resolve the host
+		addrs, err := lookup(ctx, host)
+		t.event(span, "LookupEnd")
+		if err == nil {
+			hosts.set(host, addrs)
+		}
+		return addrs, err
+	}
+
+	dial := config.DialFunc
+	config.DialFunc = func(ctx context.Context, network, address string) (net.Conn, error) {
+		span := trace.SpanFromContext(ctx)
+		if !span.IsRecording() {
+			return dial(ctx, network, address)
+		}
+
+		t.event(span, "DialStart", AddressKey.String(address))
+		// dial the server
+		conn, err := dial(ctx, network, address)
+		t.event(span, "DialEnd", AddressKey.String(address))
+		if err != nil {
+			return nil, err
+		}
+
+		return &phaseConn{Conn: conn, span: span, address: hosts.configured(network, address)}, nil
+	}
+
+	build := config.BuildFrontend
+	config.BuildFrontend = func(r io.Reader, w io.Writer) *pgproto3.Frontend {
+		// the frontend is built before the TLS handshake
+		if conn, ok := w.(*tls.Conn); ok {
+			if phase, ok := conn.NetConn().(*phaseConn); ok {
+				t.event(phase.span, "TLSStart")
+				// the startup message fails with the error of the handshake, and
+				// pgx interrupts the handshake when the context is done
+				_ = conn.Handshake()
+				t.event(phase.span, "TLSEnd")
+			}
+		}
+
+		return build(r, w)
+	}
+
+	validate := config.ValidateConnect
+	config.ValidateConnect = func(ctx context.Context, conn *pgconn.PgConn) error {
+		t.event(trace.SpanFromContext(ctx), "StartupEnd")
+
+		if validate != nil {
+			return validate(ctx, conn)
+		}
+
+		return nil
+	}
+}
+
+// phaseConn is a connection that carries the Connect span of the attempt that
+// dialed it.
+type phaseConn struct {
+	net.Conn
+	span trace.Span
+	// address is the configured host and port that was dialed
+	address string
+}
+
+// resolvedHosts maps the addresses resolved by the LookupFunc back to the
+// configured hosts.
+type resolvedHosts struct {
+	mu    sync.Mutex
+	addrs map[string][]string
+}
+
+func (r *resolvedHosts) set(host string, addrs []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.addrs[host] = addrs
+}
+
+// configured returns the configured host and port of the dialed address.
+func (r *resolvedHosts) configured(network, address string) string {
+	// the unix domain sockets are in the configured directory
+	if network == "unix" {
+		port := strings.TrimPrefix(filepath.Base(address), ".s.PGSQL.")
+		return net.JoinHostPort(filepath.Dir(address), port)
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, addrs := range r.addrs {
+		if slices.Contains(addrs, host) {
+			return net.JoinHostPort(name, port)
+		}
+	}
+	// the IP addresses are not resolved
+	return address
+}
+
+// dialedAddress returns the configured host and port dialed by the connection,
+// if it was recorded by InstrumentConnect.
+func dialedAddress(conn net.Conn) (string, bool) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+
+	if phase, ok := conn.(*phaseConn); ok {
+		return phase.address, true
+	}
+
+	return "", false
+}
@@ -0,0 +1,106 @@
+package pgxotel
+
+import (
+	"context"
+
+	pgx "github.com/jackc/pgx/v5"
+	attribute "go.opentelemetry.io/otel/attribute"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// CopyRowsKey is the attribute key for the number of rows read from a CopyFromSource.
+	CopyRowsKey = attribute.Key("db.postgresql.copy.rows")
+)
+
+// progress tracks the rows read from a CopyFromSource.
+type progress struct {
+	// span is the copy span created by the tracer
+	span trace.Span
+	// tracer is the tracer that created the span
+	tracer *QueryTracer
+	// rows is the number of rows read so far
+	rows int64
+}
+
+type progressKey struct{}
+
+func progressFrom(ctx context.Context) *progress {
+	p, _ := ctx.Value(progressKey{}).(*progress)
+	return p
+}
+
+// CopyFromSource wraps the source to record a CopyProgress event on the copy span
+// every interval rows and the number of rows read when the copy ends, even if
+// it fails. The returned context must be passed to CopyFrom.
+func CopyFromSource(ctx context.Context, src pgx.CopyFromSource, interval int64) (context.Context, pgx.CopyFromSource) {
+	p := &progress{}
+	// the tracer registers the copy span
+	ctx = context.WithValue(ctx, progressKey{}, p)
+	// done!
+	return ctx, &copyFromSource{CopyFromSource: src, progress: p, interval: interval}
+}
+
+type copyFromSource struct {
+	pgx.CopyFromSource
+	progress *progress
+	interval int64
+}
+
+// Next implements pgx.CopyFromSource.
+func (s *copyFromSource) Next() bool {
+	next := s.CopyFromSource.Next()
+	if !next {
+		return false
+	}
+
+	s.progress.rows++
+	// record the progress
+	if s.progress.span != nil && s.interval > 0 && s.progress.rows%s.interval == 0 {
+		s.progress.tracer.event(s.progress.span, "CopyProgress", CopyRowsKey.Int64(s.progress.rows))
+	}
+
+	return true
+}
+
+// TraceColumns wraps the source to append the trace ID of ctx, and its span ID if
+// spanID is set, to the values of every row. The trace columns must be the last
+// columns given to CopyFrom. The values are NULL when ctx does not have a span.
+func TraceColumns(ctx context.Context, src pgx.CopyFromSource, spanID bool) pgx.CopyFromSource {
+	s := &traceColumnsSource{CopyFromSource: src}
+
+	sc := trace.SpanContextFromContext(ctx)
+	if sc.IsValid() {
+		s.columns = append(s.columns, sc.TraceID().String())
+		if spanID {
+			s.columns = append(s.columns, sc.SpanID().String())
+		}
+	} else {
+		s.columns = append(s.columns, nil)
+		if spanID {
+			s.columns = append(s.columns, nil)
+		}
+	}
+
+	return s
+}
+
+type traceColumnsSource struct {
+	pgx.CopyFromSource
+	columns []any
+	values  []any
+}
+
+// Values implements pgx.CopyFromSource.
+func (s *traceColumnsSource) Values() ([]any, error) {
+	values, err := s.CopyFromSource.Values()
+	if err != nil {
+		return nil, err
+	}
+
+	// the values are encoded before the next row is read
+	s.values = append(s.values[:0], values...)
+	s.values = append(s.values, s.columns...)
+	// done!
+	return s.values, nil
+}
@@ -0,0 +1,70 @@
+package pgxotel_test
+
+import (
+	"context"
+	"os"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pgx-contrib/pgxotel"
+)
+
+func ExampleCopyFromSource() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	config.ConnConfig.Tracer = &pgxotel.QueryTracer{
+		Name: "example-api",
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the pool
+	defer pool.Close()
+
+	rows := [][]any{
+		{"John", "Doe"},
+		{"Jane", "Doe"},
+	}
+
+	ctx, source := pgxotel.CopyFromSource(context.TODO(), pgx.CopyFromRows(rows), 100000)
+
+	_, err = pool.CopyFrom(ctx, pgx.Identifier{"customer"}, []string{"first_name", "last_name"}, source)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func ExampleTraceColumns() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	config.ConnConfig.Tracer = &pgxotel.QueryTracer{
+		Name: "example-api",
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the pool
+	defer pool.Close()
+
+	rows := [][]any{
+		{"John", "Doe"},
+		{"Jane", "Doe"},
+	}
+
+	source := pgxotel.TraceColumns(context.TODO(), pgx.CopyFromRows(rows), false)
+
+	_, err = pool.CopyFrom(context.TODO(), pgx.Identifier{"customer"}, []string{"first_name", "last_name", "trace_id"}, source)
+	if err != nil {
+		panic(err)
+	}
+}
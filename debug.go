@@ -0,0 +1,57 @@
+//go:build pgxotel_debug
+
+package pgxotel
+
+import (
+	"fmt"
+	"strconv"
+
+	pgx "github.com/jackc/pgx/v5"
+	attribute "go.opentelemetry.io/otel/attribute"
+)
+
+// Debug reports whether the binary is built with the pgxotel_debug build tag.
+const Debug = true
+
+// values returns the values of the bind parameters.
+func (t *QueryTracer) values(args []any) []attribute.KeyValue {
+	if !t.ParameterValues || t.Minimal {
+		return nil
+	}
+
+	options, values := arguments(args)
+	for _, option := range options {
+		switch value := option.(type) {
+		case pgx.NamedArgs:
+			return namedValues(value)
+		case pgx.StrictNamedArgs:
+			return namedValues(value)
+		}
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(values))
+	for index, value := range values {
+		attrs = append(attrs, parameter(strconv.Itoa(index+1), value))
+	}
+
+	return attrs
+}
+
+func namedValues(args map[string]any) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(args))
+	for name, value := range args {
+		attrs = append(attrs, parameter(name, value))
+	}
+
+	return attrs
+}
+
+func parameter(name string, value any) attribute.KeyValue {
+	key := attribute.Key(ParameterKeyPrefix + name)
+
+	if null(value) {
+		return key.String("NULL")
+	}
+
+	return key.String(fmt.Sprint(value))
+}
@@ -0,0 +1,226 @@
+package pgxotel
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	attribute "go.opentelemetry.io/otel/attribute"
+	codes "go.opentelemetry.io/otel/codes"
+	trace "go.opentelemetry.io/otel/trace"
+	embedded "go.opentelemetry.io/otel/trace/embedded"
+)
+
+// deferred reports whether the spans are created when the operations end.
+func (t *QueryTracer) deferred() bool {
+	return t.DeferredSpans || t.ErrorsOnly || t.MinimumSpanDuration > 0
+}
+
+// keep reports whether the span of an operation that ended with the status code
+// after the duration is emitted.
+func (t *QueryTracer) keep(code codes.Code, duration time.Duration) bool {
+	if code == codes.Error {
+		return true
+	}
+
+	if t.ErrorsOnly {
+		return t.SlowThreshold > 0 && duration >= t.SlowThreshold
+	}
+
+	return duration >= t.MinimumSpanDuration
+}
+
+// deferredSpan records the data of a span that is created when it ends, so that
+// it can be dropped depending on its outcome. A span kept within it (e.g. a failed
+// query of a batch) creates it right away, so that the kept span has a parent,
+// and keeps it. It is safe for concurrent use, since the events of CopyFrom and
+// the notices are recorded from other goroutines.
+type deferredSpan struct {
+	embedded.Span
+
+	mu      sync.Mutex
+	tracer  *QueryTracer
+	parent  context.Context
+	name    string
+	options []trace.SpanStartOption
+	start   time.Time
+	attrs   []attribute.KeyValue
+	records []func(span trace.Span)
+	code    codes.Code
+	message string
+	ended   bool
+	// span is the created span, once kept
+	span trace.Span
+}
+
+// End implements trace.Span. The span is created with the recorded data unless
+// the tracer drops it.
+func (s *deferredSpan) End(opts ...trace.SpanEndOption) {
+	end := s.tracer.clock().Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+
+	s.ended = true
+	// the spans kept within it created the span already
+	if s.span == nil && !s.tracer.keep(s.code, end.Sub(s.start)) {
+		return
+	}
+
+	span := s.create()
+	span.SetStatus(s.code, s.message)
+	// the end time is the time of the operation
+	span.End(append(opts, trace.WithTimestamp(end))...)
+}
+
+// create creates the span with the data recorded so far, once. The lock must be
+// held.
+func (s *deferredSpan) create() trace.Span {
+	if s.span != nil {
+		return s.span
+	}
+
+	parent := s.parent
+	// the deferred parent is kept along with the span
+	if deferred, ok := trace.SpanFromContext(parent).(*deferredSpan); ok {
+		if span := deferred.adopt(); span != nil {
+			parent = trace.ContextWithSpan(parent, span)
+		}
+	}
+
+	options := append(s.options, trace.WithTimestamp(s.start))
+	// create the span
+	_, s.span = s.tracer.tracer().Start(parent, s.name, options...)
+	s.span.SetAttributes(s.attrs...)
+
+	for _, record := range s.records {
+		record(s.span)
+	}
+
+	s.attrs, s.records = nil, nil
+	// done!
+	return s.span
+}
+
+// adopt creates the span for a span kept within it, unless it was dropped.
+func (s *deferredSpan) adopt() trace.Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended && s.span == nil {
+		return nil
+	}
+
+	return s.create()
+}
+
+// record records the call on the span once it is created, unless it ended.
+func (s *deferredSpan) record(fn func(span trace.Span)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case s.ended:
+	case s.span != nil:
+		fn(s.span)
+	default:
+		s.records = append(s.records, fn)
+	}
+}
+
+// AddEvent implements trace.Span.
+func (s *deferredSpan) AddEvent(name string, opts ...trace.EventOption) {
+	opts = append(opts, trace.WithTimestamp(s.tracer.clock().Now()))
+	s.record(func(span trace.Span) {
+		span.AddEvent(name, opts...)
+	})
+}
+
+// AddLink implements trace.Span.
+func (s *deferredSpan) AddLink(link trace.Link) {
+	s.record(func(span trace.Span) {
+		span.AddLink(link)
+	})
+}
+
+// IsRecording implements trace.Span.
+func (s *deferredSpan) IsRecording() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return !s.ended
+}
+
+// RecordError implements trace.Span.
+func (s *deferredSpan) RecordError(err error, opts ...trace.EventOption) {
+	opts = append(opts, trace.WithTimestamp(s.tracer.clock().Now()))
+	s.record(func(span trace.Span) {
+		span.RecordError(err, opts...)
+	})
+}
+
+// SpanContext implements trace.Span. It returns the span context of the parent
+// until the span is created, so that the trace IDs propagated to the server (see
+// TraceIDParameter and TraceParentParameter) and the links to the span identify
+// the parent span.
+func (s *deferredSpan) SpanContext() trace.SpanContext {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.span != nil {
+		return s.span.SpanContext()
+	}
+
+	return trace.SpanContextFromContext(s.parent)
+}
+
+// SetStatus implements trace.Span.
+func (s *deferredSpan) SetStatus(code codes.Code, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+
+	s.code = code
+	s.message = description
+}
+
+// SetName implements trace.Span.
+func (s *deferredSpan) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+
+	s.name = name
+	if s.span != nil {
+		s.span.SetName(name)
+	}
+}
+
+// SetAttributes implements trace.Span.
+func (s *deferredSpan) SetAttributes(attrs ...attribute.KeyValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case s.ended:
+	case s.span != nil:
+		s.span.SetAttributes(attrs...)
+	default:
+		s.attrs = append(s.attrs, attrs...)
+	}
+}
+
+// TracerProvider implements trace.Span.
+func (s *deferredSpan) TracerProvider() trace.TracerProvider {
+	return trace.SpanFromContext(s.parent).TracerProvider()
+}
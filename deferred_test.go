@@ -0,0 +1,250 @@
+package pgxotel_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pgx-contrib/pgxotel"
+	"github.com/pgx-contrib/pgxotel/internal/fakepg"
+	"github.com/pgx-contrib/pgxotel/pgxoteltest"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// record records the queries that took the durations, failed with the errors,
+// under a test span whose span context it returns.
+func record(recorder *pgxoteltest.Recorder, tracer *pgxotel.QueryTracer, queries map[string]time.Duration, errs map[string]error) trace.SpanContext {
+	ctx, span := recorder.Start(context.TODO(), "test")
+	// the test span is the parent of the operations
+	defer span.End()
+
+	for sql, duration := range queries {
+		tracer.Record(ctx, pgxotel.Operation{Type: pgxotel.OperationQuery, SQL: sql, Duration: duration}, errs[sql])
+	}
+
+	return span.SpanContext()
+}
+
+func TestQueryTracer_errorsOnly(t *testing.T) {
+	recorder := pgxoteltest.NewRecorder()
+	tracer := pgxotel.NewQueryTracer("example-api", pgxotel.WithErrorsOnly(time.Second), recorder.Option())
+
+	queries := map[string]time.Duration{
+		"SELECT name FROM customer":         time.Millisecond,
+		"SELECT name FROM vendor":           2 * time.Second,
+		"UPDATE customer SET name = $1":     time.Millisecond,
+		"DELETE FROM customer WHERE id = 1": time.Millisecond,
+	}
+
+	errs := map[string]error{
+		"UPDATE customer SET name = $1": errors.New("deadlock detected"),
+	}
+
+	parent := record(recorder, tracer, queries, errs)
+	spans := recorder.Spans()
+	// the failed and the slow queries are kept
+	pgxoteltest.AssertQueryCount(t, spans, 2)
+
+	failed := pgxoteltest.AssertQuerySpan(t, spans, pgxoteltest.WithOperation("UPDATE"))
+	if failed.Status != codes.Error || failed.Error != "deadlock detected" {
+		t.Errorf("expected the failed UPDATE span, got the status %v and the error %q", failed.Status, failed.Error)
+	}
+
+	slow := pgxoteltest.AssertQuerySpan(t, spans, pgxoteltest.WithTable("vendor"))
+	if slow.Duration < 2*time.Second {
+		t.Errorf("expected the duration of the slow query, got %v", slow.Duration)
+	}
+
+	for _, span := range spans {
+		if span.Parent.SpanID() != parent.SpanID() {
+			t.Errorf("expected the %s span to be a child of the test span", span.Name)
+		}
+	}
+}
+
+// connect connects to the server with the tracer, until the test ends.
+func connect(t *testing.T, ctx context.Context, server *fakepg.Server, tracer pgx.QueryTracer) *pgx.Conn {
+	t.Helper()
+
+	config, err := pgx.ParseConfig(fakepg.ConnString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.DialFunc = server.Dial
+	config.Tracer = tracer
+
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// close the connection
+	t.Cleanup(func() { _ = conn.Close(context.Background()) })
+
+	return conn
+}
+
+// named returns the spans with the name.
+func named(spans []pgxoteltest.Span, name string) []pgxoteltest.Span {
+	matches := []pgxoteltest.Span{}
+
+	for _, span := range spans {
+		if span.Name == name {
+			matches = append(matches, span)
+		}
+	}
+
+	return matches
+}
+
+// lookup returns the first span with the name.
+func lookup(t *testing.T, spans []pgxoteltest.Span, name string) pgxoteltest.Span {
+	t.Helper()
+
+	matches := named(spans, name)
+	if len(matches) == 0 {
+		t.Fatalf("expected the %s span", name)
+	}
+
+	return matches[0]
+}
+
+func TestQueryTracer_deferredSpans(t *testing.T) {
+	recorder := pgxoteltest.NewRecorder()
+	tracer := pgxotel.NewQueryTracer("example-api", pgxotel.WithDeferredSpans(), recorder.Option())
+
+	ctx, span := recorder.Start(context.TODO(), "test")
+	conn := connect(t, ctx, &fakepg.Server{}, tracer)
+
+	ctx, tx, err := tracer.BeginTx(ctx, conn, pgx.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE customer SET name = $1", "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	span.End()
+
+	spans := recorder.Spans()
+	// the transaction is a child of the test span
+	transaction := lookup(t, spans, "Transaction")
+	if transaction.Parent.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("expected the Transaction span to be a child of the test span")
+	}
+
+	children := map[trace.SpanID][]string{}
+	for _, span := range spans {
+		children[span.Parent.SpanID()] = append(children[span.Parent.SpanID()], span.Name)
+	}
+	// the statement is prepared within the query
+	expected := []string{"begin", "UPDATE customer SET name = $1", "commit"}
+	if names := children[transaction.SpanContext.SpanID()]; !slices.Equal(names, expected) {
+		t.Errorf("expected the children %q of the Transaction span, got %q", expected, names)
+	}
+
+	for _, span := range spans {
+		if span.Parent.SpanID() == transaction.SpanContext.SpanID() && span.Name == expected[1] {
+			if names := children[span.SpanContext.SpanID()]; len(names) != 1 {
+				t.Errorf("expected the prepared statement to be a child of the UPDATE span, got %q", names)
+			}
+		}
+	}
+}
+
+func TestQueryTracer_deferredSpansErrorsOnly(t *testing.T) {
+	recorder := pgxoteltest.NewRecorder()
+	tracer := pgxotel.NewQueryTracer("example-api", pgxotel.WithErrorsOnly(0), recorder.Option())
+
+	server := &fakepg.Server{
+		Handler: func(sql string, _ []string) fakepg.Result {
+			if sql == "UPDATE customer SET name = $1" {
+				return fakepg.Result{Err: &pgconn.PgError{Code: "40P01", Message: "deadlock detected"}}
+			}
+
+			return fakepg.Result{}
+		},
+	}
+
+	ctx, span := recorder.Start(context.TODO(), "test")
+	conn := connect(t, ctx, server, tracer)
+
+	ctx, tx, err := tracer.BeginTx(ctx, conn, pgx.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE customer SET name = $1", "alice"); err == nil {
+		t.Fatal("expected the deadlock")
+	}
+
+	if err := tx.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	span.End()
+
+	spans := recorder.Spans()
+	// the transaction is kept along with the failed query
+	if len(spans) != 2 {
+		t.Fatalf("expected the Transaction and the UPDATE spans, got %d spans", len(spans))
+	}
+
+	transaction := lookup(t, spans, "Transaction")
+	if transaction.Parent.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("expected the Transaction span to be a child of the test span")
+	}
+
+	query := pgxoteltest.AssertQuerySpan(t, spans, pgxoteltest.WithOperation("UPDATE"))
+	if query.Parent.SpanID() != transaction.SpanContext.SpanID() {
+		t.Errorf("expected the UPDATE span to be a child of the Transaction span")
+	}
+
+	if query.Status != codes.Error || query.Error != "ERROR: deadlock detected (SQLSTATE 40P01)" {
+		t.Errorf("expected the deadlock on the UPDATE span, got the status %v and the error %q", query.Status, query.Error)
+	}
+}
+
+func TestQueryTracer_minimumSpanDuration(t *testing.T) {
+	recorder := pgxoteltest.NewRecorder()
+	tracer := pgxotel.NewQueryTracer("example-api", pgxotel.WithMinimumSpanDuration(10*time.Millisecond), recorder.Option())
+
+	queries := map[string]time.Duration{
+		"SELECT name FROM customer WHERE id = $1": time.Millisecond,
+		"SELECT name FROM customer":               10 * time.Millisecond,
+		"UPDATE customer SET name = $1":           time.Millisecond,
+	}
+
+	errs := map[string]error{
+		"UPDATE customer SET name = $1": errors.New("deadlock detected"),
+	}
+
+	parent := record(recorder, tracer, queries, errs)
+	spans := recorder.Spans()
+	// the fast lookup is dropped
+	pgxoteltest.AssertQueryCount(t, spans, 2)
+	pgxoteltest.AssertQueryCount(t, spans, 0, pgxoteltest.WithStatement("SELECT name FROM customer WHERE id = $1"))
+
+	slow := pgxoteltest.AssertQuerySpan(t, spans, pgxoteltest.WithStatement("SELECT name FROM customer"))
+	if slow.Duration < 10*time.Millisecond {
+		t.Errorf("expected the duration of the slow query, got %v", slow.Duration)
+	}
+
+	pgxoteltest.AssertQuerySpan(t, spans, pgxoteltest.WithOperation("UPDATE"), pgxoteltest.WithError(errors.New("deadlock detected")))
+
+	for _, span := range spans {
+		if span.Parent.SpanID() != parent.SpanID() {
+			t.Errorf("expected the %s span to be a child of the test span", span.Name)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package pgxotel
+
+import (
+	"context"
+
+	pgx "github.com/jackc/pgx/v5"
+	otel "go.opentelemetry.io/otel"
+	attribute "go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// InstanceIDKey is the attribute key for the database instance identifier.
+	InstanceIDKey = attribute.Key("db.instance.id")
+	// ServerVersionKey is the attribute key for the version of the server.
+	ServerVersionKey = attribute.Key("db.version")
+	// SuperuserKey is the attribute key that marks the connections of superusers.
+	SuperuserKey = attribute.Key("db.postgresql.superuser")
+	// TimeZoneKey is the attribute key for the time zone of the session.
+	TimeZoneKey = attribute.Key("db.postgresql.timezone")
+)
+
+// instanceIDKey is the connection custom data key of the discovered instance ID.
+const instanceIDKey = "pgxotel.instance_id"
+
+// discover queries the facts about the connection the tracer records. The facts
+// that cannot be discovered are not recorded.
+func (t *QueryTracer) discover(ctx context.Context, conn *pgx.Conn) {
+	ctx = untraced(ctx)
+
+	if t.DiscoverInstanceID {
+		// the connection is healthy nonetheless
+		if err := t.discoverInstanceID(ctx, conn); err != nil {
+			otel.Handle(err)
+		}
+	}
+
+	if t.DiscoverServerFacts && !t.Minimal {
+		if err := t.discoverServerFacts(ctx, conn); err != nil {
+			otel.Handle(err)
+		}
+	}
+}
+
+func (t *QueryTracer) discoverInstanceID(ctx context.Context, conn *pgx.Conn) error {
+	var id string
+	// prefer the configured cluster name
+	if err := conn.QueryRow(ctx, "SELECT current_setting('cluster_name')").Scan(&id); err != nil {
+		return err
+	}
+
+	if id == "" {
+		// pg_control_system might not be accessible to the current role
+		if err := conn.QueryRow(ctx, "SELECT system_identifier::text FROM pg_control_system()").Scan(&id); err != nil {
+			id = ""
+		}
+	}
+
+	if id != "" {
+		conn.PgConn().CustomData()[instanceIDKey] = id
+	}
+
+	return nil
+}
+
+// discoverServerFacts queries the settings of the session once, which are cached
+// with the attributes of the connection.
+func (t *QueryTracer) discoverServerFacts(ctx context.Context, conn *pgx.Conn) error {
+	var version, superuser, zone string
+
+	row := conn.QueryRow(ctx, "SELECT current_setting('server_version'), current_setting('is_superuser'), current_setting('TimeZone')")
+	if err := row.Scan(&version, &superuser, &zone); err != nil {
+		return err
+	}
+
+	cache := t.cache(conn)
+	// the version is recorded already
+	if !t.ServerVersion {
+		cache.attrs = append(cache.attrs, ServerVersionKey.String(version))
+	}
+	cache.attrs = append(cache.attrs, SuperuserKey.Bool(superuser == "on"))
+	cache.attrs = append(cache.attrs, TimeZoneKey.String(zone))
+	// done!
+	return nil
+}
+
+// instance returns the instance ID of the connection, which might be nil.
+func (t *QueryTracer) instance(conn *pgx.Conn) []attribute.KeyValue {
+	if conn != nil {
+		if id, ok := conn.PgConn().CustomData()[instanceIDKey].(string); ok {
+			return []attribute.KeyValue{InstanceIDKey.String(id)}
+		}
+	}
+
+	if t.InstanceID != "" {
+		return []attribute.KeyValue{InstanceIDKey.String(t.InstanceID)}
+	}
+
+	return nil
+}
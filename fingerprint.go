@@ -0,0 +1,76 @@
+package pgxotel
+
+import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	attribute "go.opentelemetry.io/otel/attribute"
+)
+
+// FingerprintKey is the attribute key for the fingerprint of a statement: a hash of
+// the statement normalized by LiteralSanitizer, with the literals and the
+// parameters replaced by the same placeholder. It is the same for the statements
+// that only differ by their literals, parameters, comments or list lengths, like
+// the normalized statements of pg_stat_statements.
+const FingerprintKey = attribute.Key("db.query.fingerprint")
+
+// Fingerprint returns the fingerprint of the query.
+func Fingerprint(query string) string {
+	hash := fnv.New64a()
+	// the hash never fails
+	_, _ = hash.Write([]byte(normalizeParameters(LiteralSanitizer{}.Sanitize(query))))
+	// done!
+	return strconv.FormatUint(hash.Sum64(), 16)
+}
+
+// normalizeParameters replaces the positional parameters ($1) of the sanitized query with
+// the placeholder of the literals (?), since pg_stat_statements normalizes the
+// constants into parameters.
+func normalizeParameters(query string) string {
+	if !strings.Contains(query, "$") {
+		return query
+	}
+
+	builder := &strings.Builder{}
+	builder.Grow(len(query))
+
+	for index := 0; index < len(query); {
+		c := query[index]
+		switch {
+		case c == '"':
+			next := quoted(query, index, '"', false)
+			builder.WriteString(query[index:next])
+			index = next
+		case isIdentifier(c) && c != '$':
+			// the identifiers might contain $
+			next := index
+			for next < len(query) && isIdentifier(query[next]) {
+				next++
+			}
+
+			builder.WriteString(query[index:next])
+			index = next
+		case c == '$' && index+1 < len(query) && isDigit(query[index+1]):
+			index++
+			for index < len(query) && isDigit(query[index]) {
+				index++
+			}
+
+			builder.WriteByte('?')
+		default:
+			builder.WriteByte(c)
+			index++
+		}
+	}
+
+	return builder.String()
+}
+
+func (t *QueryTracer) fingerprint(query string) []attribute.KeyValue {
+	if !t.Fingerprint || t.Minimal {
+		return nil
+	}
+
+	return []attribute.KeyValue{FingerprintKey.String(t.fingerprints.get(query, Fingerprint))}
+}
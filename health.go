@@ -0,0 +1,110 @@
+package pgxotel
+
+import (
+	"context"
+	"time"
+
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	pgxpool "github.com/jackc/pgx/v5/pgxpool"
+	attribute "go.opentelemetry.io/otel/attribute"
+	metric "go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+// HealthCheck controls how Monitor probes a pool.
+type HealthCheck struct {
+	// Query is the probe query (defaults to SELECT 1)
+	Query string
+	// Interval is the delay between two probes (defaults to 30 seconds)
+	Interval time.Duration
+	// Timeout is the timeout of a probe (defaults to 5 seconds)
+	Timeout time.Duration
+}
+
+func (c HealthCheck) query() string {
+	if c.Query != "" {
+		return c.Query
+	}
+
+	return "SELECT 1"
+}
+
+func (c HealthCheck) interval() time.Duration {
+	if c.Interval > 0 {
+		return c.Interval
+	}
+
+	return 30 * time.Second
+}
+
+func (c HealthCheck) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+
+	return 5 * time.Second
+}
+
+// Monitor probes the pool every interval until ctx is done, which it returns the
+// error of. Every probe creates a Probe span, under the recording span of ctx or
+// as a root span with RootSpans, and records the db.client.health.up gauge (1 when
+// the probe succeeded, 0 otherwise) and the db.client.health.last_success gauge
+// (the Unix time of the last successful probe). It is meant to run in its own
+// goroutine.
+func Monitor(ctx context.Context, pool *pgxpool.Pool, check HealthCheck) error {
+	t, ok := lookup(pool.Config().ConnConfig.Tracer)
+	if !ok {
+		t = &QueryTracer{}
+	}
+
+	ticker := t.clock().NewTicker(check.interval())
+	// stop the ticker
+	defer ticker.Stop()
+
+	for {
+		t.probe(ctx, pool, check)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
+
+func (t *QueryTracer) probe(ctx context.Context, pool *pgxpool.Pool, check HealthCheck) {
+	ctx, cancel := context.WithTimeout(ctx, check.timeout())
+	// release the timer
+	defer cancel()
+
+	var span trace.Span
+	// the probes are background work
+	if !t.DisableSpans && (t.RootSpans || trace.SpanFromContext(ctx).IsRecording()) {
+		ctx, span = t.start(ctx, "Probe", nil, trace.WithSpanKind(trace.SpanKindInternal))
+	}
+
+	_, err := pool.Exec(ctx, check.query())
+
+	if span != nil {
+		// done
+		t.stop(ctx, span, pgconn.CommandTag{}, err, nil)
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBName(pool.Config().ConnConfig.Database),
+	}
+	attrs = stabilize(attrs)
+	attrs = append(attrs, t.poolName()...)
+	options := metric.WithAttributes(attrs...)
+
+	metrics := t.instruments()
+	if err != nil {
+		metrics.up.Record(ctx, 0, options)
+		return
+	}
+
+	metrics.up.Record(ctx, 1, options)
+	metrics.success.Record(ctx, t.clock().Now().Unix(), options)
+}
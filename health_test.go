@@ -0,0 +1,38 @@
+package pgxotel_test
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pgx-contrib/pgxotel"
+)
+
+func ExampleMonitor() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	config.ConnConfig.Tracer = &pgxotel.QueryTracer{
+		Name: "example-api",
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the pool
+	defer pool.Close()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	// stop the monitor
+	defer cancel()
+
+	check := pgxotel.HealthCheck{
+		Interval: 10 * time.Second,
+	}
+
+	go pgxotel.Monitor(ctx, pool, check)
+}
@@ -0,0 +1,121 @@
+package pgxotel
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	attribute "go.opentelemetry.io/otel/attribute"
+)
+
+// HintPrefix is the prefix of the hints of a line comment such as
+// "-- otel:name=FindOrders otel:attr.tier=critical". The name hint sets the name of
+// the span and the attr hints add string attributes to it. The comments are
+// stripped from the recorded statement.
+const HintPrefix = "otel:"
+
+// hint is the span metadata parsed from the hint comments of a query.
+type hint struct {
+	// name is the name of the span, if any
+	name string
+	// attrs are the attributes of the span
+	attrs []attribute.KeyValue
+	// query is the query without the hint comments
+	query string
+}
+
+// hint returns the hint of the query.
+func (t *QueryTracer) hint(query string) *hint {
+	return t.hints.get(query, parseHint)
+}
+
+// hinted reports whether the query may have hint comments.
+func hinted(query string) bool {
+	return strings.Contains(query, HintPrefix)
+}
+
+func parseHint(query string) *hint {
+	h := &hint{}
+
+	builder := &strings.Builder{}
+	builder.Grow(len(query))
+
+	begin := 0
+	for index := 0; index < len(query); {
+		switch c := query[index]; {
+		case strings.HasPrefix(query[index:], "--"):
+			end := lineComment(query, index)
+			// strip the comment when it has hints
+			if h.parse(query[index+2 : end]) {
+				builder.WriteString(query[begin:index])
+				begin = end
+			}
+			index = end
+		case strings.HasPrefix(query[index:], "/*"):
+			index = blockComment(query, index)
+		case c == '\'':
+			index = quoted(query, index, '\'', escaped(query[:index]))
+		case c == '"':
+			index = quoted(query, index, '"', false)
+		case c == '$':
+			index = dollarQuoted(query, index, query[:index])
+		default:
+			index++
+		}
+	}
+
+	builder.WriteString(query[begin:])
+	h.query = strings.TrimSpace(builder.String())
+	// done
+	return h
+}
+
+// parse parses the hints of the comment and reports whether it has any.
+func (h *hint) parse(comment string) bool {
+	fields := strings.Fields(comment)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], HintPrefix) {
+		return false
+	}
+
+	for _, field := range fields {
+		key, value, ok := strings.Cut(strings.TrimPrefix(field, HintPrefix), "=")
+		if !ok || value == "" {
+			continue
+		}
+
+		switch {
+		case key == "name":
+			h.name = value
+		case strings.HasPrefix(key, "attr.") && len(key) > len("attr."):
+			h.attrs = append(h.attrs, attribute.String(key[len("attr."):], value))
+		}
+	}
+
+	return true
+}
+
+var pattern = regexp.MustCompile(`^\s*name:\s+(\w+)`)
+
+// commentName returns the name of the first "-- name: X" or "/* name: X */"
+// comment in the leading comment section of the query.
+func commentName(query string) (string, bool) {
+	for {
+		query = strings.TrimLeftFunc(query, unicode.IsSpace)
+
+		var comment string
+		switch {
+		case strings.HasPrefix(query, "--"):
+			end := lineComment(query, 0)
+			comment, query = query[2:end], query[end:]
+		case strings.HasPrefix(query, "/*"):
+			end := blockComment(query, 0)
+			comment, query = strings.TrimSuffix(query[2:end], "*/"), query[end:]
+		default:
+			return "", false
+		}
+
+		if match := pattern.FindStringSubmatch(comment); len(match) == 2 {
+			return match[1], true
+		}
+	}
+}
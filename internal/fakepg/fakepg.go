@@ -0,0 +1,362 @@
+// Package fakepg serves the PostgreSQL protocol in memory, so that the tests
+// exercise the tracer through real pgx connections without a server.
+package fakepg
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	pgproto3 "github.com/jackc/pgx/v5/pgproto3"
+)
+
+// ConnString is the connection string of the server, whose connections are
+// served by Dial.
+const ConnString = "postgres://postgres@127.0.0.1:5432/example?sslmode=disable"
+
+// textOID is the type of the parameters and the columns.
+const textOID = 25
+
+// parameter matches the parameters of a statement.
+var parameter = regexp.MustCompile(`\$([0-9]+)`)
+
+// Result is the result of a query.
+type Result struct {
+	// Columns are the names of the text columns
+	Columns []string
+	// Rows are the rows of the result
+	Rows [][]string
+	// Tag is the command tag (defaults to the keyword of the query, with the
+	// number of rows for SELECT)
+	Tag string
+	// Err fails the query
+	Err *pgconn.PgError
+}
+
+// Query is a query executed by the server.
+type Query struct {
+	// SQL is the statement of the query
+	SQL string
+	// Args are the text arguments of the query
+	Args []string
+}
+
+// Server answers the queries of its connections with the Handler.
+type Server struct {
+	// Handler answers the queries, with an empty result by default. It also
+	// describes the statements, so that it must not depend on the arguments to
+	// return the columns.
+	Handler func(sql string, args []string) Result
+
+	mu      sync.Mutex
+	queries []Query
+	startup []map[string]string
+	pid     uint32
+}
+
+// Dial serves a new connection. It can be used as pgconn.Config.DialFunc.
+func (s *Server) Dial(ctx context.Context, network, address string) (net.Conn, error) {
+	client, server := net.Pipe()
+
+	s.mu.Lock()
+	s.pid++
+	pid := s.pid
+	s.mu.Unlock()
+
+	go s.serve(server, pid)
+	// done!
+	return client, nil
+}
+
+// Queries returns the queries executed so far.
+func (s *Server) Queries() []Query {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queries := make([]Query, len(s.queries))
+	copy(queries, s.queries)
+	return queries
+}
+
+// Startup returns the startup parameters of the connections.
+func (s *Server) Startup() []map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	startup := make([]map[string]string, len(s.startup))
+	copy(startup, s.startup)
+	return startup
+}
+
+// session is the state of a connection.
+type session struct {
+	server     *Server
+	backend    *pgproto3.Backend
+	statements map[string]string
+	portal     portal
+	status     byte
+	failed     bool
+}
+
+// portal is the bound statement.
+type portal struct {
+	sql     string
+	args    []string
+	formats []int16
+}
+
+func (s *Server) serve(conn net.Conn, pid uint32) {
+	// close the connection
+	defer conn.Close()
+
+	backend := pgproto3.NewBackend(conn, conn)
+	if err := s.start(conn, backend, pid); err != nil {
+		return
+	}
+
+	session := &session{
+		server:     s,
+		backend:    backend,
+		statements: map[string]string{},
+		status:     'I',
+	}
+
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+
+		if _, ok := msg.(*pgproto3.Terminate); ok {
+			return
+		}
+
+		if err := session.handle(msg); err != nil {
+			return
+		}
+	}
+}
+
+// start accepts the startup message of the connection.
+func (s *Server) start(conn net.Conn, backend *pgproto3.Backend, pid uint32) error {
+	msg, err := backend.ReceiveStartupMessage()
+	if err != nil {
+		return err
+	}
+	// the encryption is declined
+	if _, ok := msg.(*pgproto3.SSLRequest); ok {
+		if _, err := conn.Write([]byte("N")); err != nil {
+			return err
+		}
+
+		if msg, err = backend.ReceiveStartupMessage(); err != nil {
+			return err
+		}
+	}
+
+	startup, ok := msg.(*pgproto3.StartupMessage)
+	if !ok {
+		return fmt.Errorf("fakepg: unexpected startup message %T", msg)
+	}
+
+	s.mu.Lock()
+	s.startup = append(s.startup, startup.Parameters)
+	s.mu.Unlock()
+
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.ParameterStatus{Name: "server_version", Value: "16.4"})
+	backend.Send(&pgproto3.ParameterStatus{Name: "client_encoding", Value: "UTF8"})
+	backend.Send(&pgproto3.ParameterStatus{Name: "standard_conforming_strings", Value: "on"})
+	backend.Send(&pgproto3.ParameterStatus{Name: "application_name", Value: startup.Parameters["application_name"]})
+	backend.Send(&pgproto3.BackendKeyData{ProcessID: pid, SecretKey: pid})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	// done!
+	return backend.Flush()
+}
+
+func (c *session) handle(msg pgproto3.FrontendMessage) error {
+	// the extended protocol skips the messages until the next sync after an error
+	if _, ok := msg.(*pgproto3.Sync); !ok && c.failed {
+		return nil
+	}
+
+	switch msg := msg.(type) {
+	case *pgproto3.Query:
+		c.query(msg.String)
+		c.backend.Send(&pgproto3.ReadyForQuery{TxStatus: c.status})
+		return c.backend.Flush()
+	case *pgproto3.Parse:
+		c.statements[msg.Name] = msg.Query
+		c.backend.Send(&pgproto3.ParseComplete{})
+	case *pgproto3.Describe:
+		c.describe(msg)
+	case *pgproto3.Bind:
+		c.portal = portal{sql: c.statements[msg.PreparedStatement], formats: msg.ResultFormatCodes}
+		for _, arg := range msg.Parameters {
+			c.portal.args = append(c.portal.args, string(arg))
+		}
+		c.backend.Send(&pgproto3.BindComplete{})
+	case *pgproto3.Execute:
+		c.execute(c.portal.sql, c.portal.args, false)
+	case *pgproto3.Close:
+		delete(c.statements, msg.Name)
+		c.backend.Send(&pgproto3.CloseComplete{})
+	case *pgproto3.Flush:
+		return c.backend.Flush()
+	case *pgproto3.Sync:
+		c.failed = false
+		c.backend.Send(&pgproto3.ReadyForQuery{TxStatus: c.status})
+		return c.backend.Flush()
+	default:
+		return fmt.Errorf("fakepg: unexpected message %T", msg)
+	}
+
+	return nil
+}
+
+// query executes a query of the simple protocol.
+func (c *session) query(sql string) {
+	if strings.TrimSpace(sql) == "" {
+		c.backend.Send(&pgproto3.EmptyQueryResponse{})
+		return
+	}
+
+	c.execute(sql, nil, true)
+}
+
+// describe describes the statement or the portal.
+func (c *session) describe(msg *pgproto3.Describe) {
+	sql, formats := c.portal.sql, c.portal.formats
+	if msg.ObjectType == 'S' {
+		sql, formats = c.statements[msg.Name], nil
+
+		description := &pgproto3.ParameterDescription{}
+		for range count(sql) {
+			description.ParameterOIDs = append(description.ParameterOIDs, textOID)
+		}
+		c.backend.Send(description)
+	}
+
+	result := c.server.handler(sql, nil)
+	if len(result.Columns) == 0 {
+		c.backend.Send(&pgproto3.NoData{})
+		return
+	}
+
+	c.backend.Send(fields(result.Columns, formats))
+}
+
+// execute executes the query and sends its rows.
+func (c *session) execute(sql string, args []string, describe bool) {
+	c.server.mu.Lock()
+	c.server.queries = append(c.server.queries, Query{SQL: sql, Args: args})
+	c.server.mu.Unlock()
+
+	result := c.server.handler(sql, args)
+	if result.Err == nil && c.status == 'E' && !rollback(sql) {
+		result.Err = &pgconn.PgError{Code: "25P02", Message: "current transaction is aborted, commands ignored until end of transaction block"}
+	}
+
+	if result.Err != nil {
+		c.backend.Send(&pgproto3.ErrorResponse{
+			Severity: "ERROR",
+			Code:     result.Err.Code,
+			Message:  result.Err.Message,
+		})
+
+		c.failed = !describe
+		if c.status == 'T' {
+			c.status = 'E'
+		}
+		return
+	}
+
+	if describe && len(result.Columns) > 0 {
+		c.backend.Send(fields(result.Columns, nil))
+	}
+
+	for _, row := range result.Rows {
+		values := make([][]byte, len(row))
+		for index, value := range row {
+			values[index] = []byte(value)
+		}
+		c.backend.Send(&pgproto3.DataRow{Values: values})
+	}
+
+	keyword := strings.ToUpper(strings.Fields(sql)[0])
+	tag := result.Tag
+	if tag == "" {
+		tag = keyword
+		if keyword == "SELECT" {
+			tag = "SELECT " + strconv.Itoa(len(result.Rows))
+		}
+	}
+
+	switch {
+	case keyword == "BEGIN" || keyword == "START":
+		c.status = 'T'
+	case keyword == "COMMIT" && c.status == 'E':
+		c.status, tag = 'I', "ROLLBACK"
+	case keyword == "COMMIT" || keyword == "END" || rollback(sql) && !strings.Contains(strings.ToUpper(sql), " TO "):
+		c.status = 'I'
+	case rollback(sql):
+		c.status = 'T'
+	}
+
+	c.backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(tag)})
+}
+
+func (s *Server) handler(sql string, args []string) Result {
+	if s.Handler == nil {
+		return Result{}
+	}
+
+	return s.Handler(sql, args)
+}
+
+// rollback reports whether the query rolls back the transaction or a savepoint.
+func rollback(sql string) bool {
+	return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(sql)), "ROLLBACK")
+}
+
+// count returns the number of parameters of the statement.
+func count(sql string) int {
+	n := 0
+	for _, match := range parameter.FindAllStringSubmatch(sql, -1) {
+		if index, _ := strconv.Atoi(match[1]); index > n {
+			n = index
+		}
+	}
+
+	return n
+}
+
+// fields describes the text columns in the formats requested by the client.
+func fields(columns []string, formats []int16) *pgproto3.RowDescription {
+	description := &pgproto3.RowDescription{}
+
+	for index, column := range columns {
+		field := pgproto3.FieldDescription{
+			Name:         []byte(column),
+			DataTypeOID:  textOID,
+			DataTypeSize: -1,
+			TypeModifier: -1,
+		}
+
+		switch {
+		case len(formats) == 1:
+			field.Format = formats[0]
+		case index < len(formats):
+			field.Format = formats[index]
+		}
+
+		description.Fields = append(description.Fields, field)
+	}
+
+	return description
+}
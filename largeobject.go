@@ -0,0 +1,205 @@
+package pgxotel
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	pgx "github.com/jackc/pgx/v5"
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	attribute "go.opentelemetry.io/otel/attribute"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// LargeObjectOIDKey is the attribute key for the OID of a large object.
+	LargeObjectOIDKey = attribute.Key("db.postgresql.large_object.oid")
+	// LargeObjectModeKey is the attribute key for the mode (read, write or
+	// read_write) a large object is opened with.
+	LargeObjectModeKey = attribute.Key("db.postgresql.large_object.mode")
+	// LargeObjectBytesKey is the attribute key for the number of bytes read from or
+	// written to a large object.
+	LargeObjectBytesKey = attribute.Key("db.postgresql.large_object.bytes")
+	// LargeObjectOffsetKey is the attribute key for the offset of a large object
+	// after a seek or before a truncate.
+	LargeObjectOffsetKey = attribute.Key("db.postgresql.large_object.offset")
+)
+
+// LargeObjects wraps the large objects of a transaction with LargeObject spans.
+// The statements issued by the large objects are not traced.
+type LargeObjects struct {
+	objects pgx.LargeObjects
+	tracer  *QueryTracer
+	conn    *pgx.Conn
+}
+
+// LargeObjects returns the large objects of the transaction, traced by the tracer.
+func (t *QueryTracer) LargeObjects(tx pgx.Tx) *LargeObjects {
+	return &LargeObjects{objects: tx.LargeObjects(), tracer: t, conn: tx.Conn()}
+}
+
+// Create creates a new large object, with a generated OID when oid is zero.
+func (o *LargeObjects) Create(ctx context.Context, oid uint32) (uint32, error) {
+	ctx, span := o.tracer.largeObject(ctx, o.conn, "LargeObjectCreate", oid)
+
+	oid, err := o.objects.Create(untraced(ctx), oid)
+	if span != nil {
+		attrs := []attribute.KeyValue{}
+		attrs = append(attrs, LargeObjectOIDKey.Int64(int64(oid)))
+		// done!
+		o.tracer.stop(ctx, span, pgconn.CommandTag{}, err, attrs)
+	}
+
+	return oid, err
+}
+
+// Open opens the large object with the given mode. The reads and writes of the
+// large object are traced as children of the span of ctx.
+func (o *LargeObjects) Open(ctx context.Context, oid uint32, mode pgx.LargeObjectMode) (*LargeObject, error) {
+	parent := ctx
+	ctx, span := o.tracer.largeObject(ctx, o.conn, "LargeObjectOpen", oid)
+	if span != nil && span.IsRecording() {
+		o.tracer.annotate(span, LargeObjectModeKey.String(objectMode(mode)))
+	}
+
+	object, err := o.objects.Open(untraced(parent), oid, mode)
+	if span != nil {
+		o.tracer.stop(ctx, span, pgconn.CommandTag{}, err, nil)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &LargeObject{object: object, tracer: o.tracer, conn: o.conn, ctx: parent, oid: oid}, nil
+}
+
+// Unlink removes the large object.
+func (o *LargeObjects) Unlink(ctx context.Context, oid uint32) error {
+	ctx, span := o.tracer.largeObject(ctx, o.conn, "LargeObjectUnlink", oid)
+
+	err := o.objects.Unlink(untraced(ctx), oid)
+	if span != nil {
+		o.tracer.stop(ctx, span, pgconn.CommandTag{}, err, nil)
+	}
+
+	return err
+}
+
+// LargeObject is a large object opened by LargeObjects, whose operations are
+// traced by LargeObject spans. It implements io.Reader, io.Writer, io.Seeker and
+// io.Closer.
+type LargeObject struct {
+	object *pgx.LargeObject
+	tracer *QueryTracer
+	conn   *pgx.Conn
+	ctx    context.Context
+	oid    uint32
+}
+
+// Read reads up to len(p) bytes from the large object.
+func (o *LargeObject) Read(p []byte) (int, error) {
+	ctx, span := o.tracer.largeObject(o.ctx, o.conn, "LargeObjectRead", o.oid)
+
+	n, err := o.object.Read(p)
+	if span != nil {
+		attrs := []attribute.KeyValue{}
+		attrs = append(attrs, LargeObjectBytesKey.Int(n))
+		// the end of the object is not a failure
+		if errors.Is(err, io.EOF) {
+			o.tracer.stop(ctx, span, pgconn.CommandTag{}, nil, attrs)
+		} else {
+			o.tracer.stop(ctx, span, pgconn.CommandTag{}, err, attrs)
+		}
+	}
+
+	return n, err
+}
+
+// Write writes p to the large object.
+func (o *LargeObject) Write(p []byte) (int, error) {
+	ctx, span := o.tracer.largeObject(o.ctx, o.conn, "LargeObjectWrite", o.oid)
+
+	n, err := o.object.Write(p)
+	if span != nil {
+		attrs := []attribute.KeyValue{}
+		attrs = append(attrs, LargeObjectBytesKey.Int(n))
+		// done!
+		o.tracer.stop(ctx, span, pgconn.CommandTag{}, err, attrs)
+	}
+
+	return n, err
+}
+
+// Seek moves the current position of the large object.
+func (o *LargeObject) Seek(offset int64, whence int) (int64, error) {
+	ctx, span := o.tracer.largeObject(o.ctx, o.conn, "LargeObjectSeek", o.oid)
+
+	n, err := o.object.Seek(offset, whence)
+	if span != nil {
+		attrs := []attribute.KeyValue{}
+		attrs = append(attrs, LargeObjectOffsetKey.Int64(n))
+		// done!
+		o.tracer.stop(ctx, span, pgconn.CommandTag{}, err, attrs)
+	}
+
+	return n, err
+}
+
+// Tell returns the current position of the large object. It is not traced.
+func (o *LargeObject) Tell() (int64, error) {
+	return o.object.Tell()
+}
+
+// Truncate truncates the large object to the size.
+func (o *LargeObject) Truncate(size int64) error {
+	ctx, span := o.tracer.largeObject(o.ctx, o.conn, "LargeObjectTruncate", o.oid)
+
+	err := o.object.Truncate(size)
+	if span != nil {
+		attrs := []attribute.KeyValue{}
+		attrs = append(attrs, LargeObjectOffsetKey.Int64(size))
+		// done!
+		o.tracer.stop(ctx, span, pgconn.CommandTag{}, err, attrs)
+	}
+
+	return err
+}
+
+// Close closes the large object descriptor. It is not traced.
+func (o *LargeObject) Close() error {
+	return o.object.Close()
+}
+
+// largeObject starts the span of a large object operation. The span is nil when
+// the operation is not traced.
+func (t *QueryTracer) largeObject(ctx context.Context, conn *pgx.Conn, name string, oid uint32) (context.Context, trace.Span) {
+	if !trace.SpanFromContext(ctx).IsRecording() || t.disabled(OperationLargeObject) {
+		return ctx, nil
+	}
+
+	buffer := newBuffer()
+	attrs := buffer.attrs
+	attrs = append(attrs, t.cache(conn).attrs...)
+	attrs = append(attrs, t.instance(conn)...)
+	if oid != 0 {
+		attrs = append(attrs, LargeObjectOIDKey.Int64(int64(oid)))
+	}
+	// prepare the span
+	ctx, span := t.start(ctx, name, attrs, t.SpanStartOptions[OperationLargeObject]...)
+	buffer.free(attrs)
+	// done!
+	return ctx, span
+}
+
+// objectMode returns the name of the mode of a large object.
+func objectMode(mode pgx.LargeObjectMode) string {
+	switch mode {
+	case pgx.LargeObjectModeRead:
+		return "read"
+	case pgx.LargeObjectModeWrite:
+		return "write"
+	default:
+		return "read_write"
+	}
+}
@@ -0,0 +1,55 @@
+package pgxotel_test
+
+import (
+	"context"
+	"os"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pgx-contrib/pgxotel"
+)
+
+func ExampleQueryTracer_LargeObjects() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	tracer := pgxotel.Configure(config)
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the pool
+	defer pool.Close()
+
+	tx, err := pool.Begin(context.TODO())
+	if err != nil {
+		panic(err)
+	}
+	// rollback the transaction on error
+	defer tx.Rollback(context.TODO())
+
+	objects := tracer.LargeObjects(tx)
+
+	oid, err := objects.Create(context.TODO(), 0)
+	if err != nil {
+		panic(err)
+	}
+
+	object, err := objects.Open(context.TODO(), oid, pgx.LargeObjectModeWrite)
+	if err != nil {
+		panic(err)
+	}
+	// close the object
+	defer object.Close()
+
+	if _, err := object.Write([]byte("hello")); err != nil {
+		panic(err)
+	}
+
+	if err := tx.Commit(context.TODO()); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,149 @@
+package pgxotel
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"unicode/utf8"
+
+	attribute "go.opentelemetry.io/otel/attribute"
+	metric "go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// LimitKey is the attribute key for the limit that dropped or truncated
+	// telemetry.
+	LimitKey = attribute.Key("pgxotel.limit")
+	// TruncationMarker ends the values truncated by MaxAttributeBytes, so that they
+	// tell apart from the values that fit.
+	TruncationMarker = "...[truncated]"
+)
+
+var (
+	// limitEvents marks the events dropped by MaxEvents.
+	limitEvents = metric.WithAttributes(semconv.DBSystemPostgreSQL, LimitKey.String("events"))
+	// limitAttributeBytes marks the values truncated by MaxAttributeBytes.
+	limitAttributeBytes = metric.WithAttributes(semconv.DBSystemPostgreSQL, LimitKey.String("attribute_bytes"))
+)
+
+// allow reports whether the span can record another event.
+func (t *QueryTracer) allow(span trace.Span) bool {
+	if t.MaxEvents <= 0 {
+		return true
+	}
+
+	value, ok := t.events.Load(span)
+	if !ok {
+		// the spans of the application are not limited
+		return true
+	}
+
+	if value.(*atomic.Int64).Add(1) <= int64(t.MaxEvents) {
+		return true
+	}
+
+	t.recordLimited(limitEvents)
+	return false
+}
+
+// own counts the events of a span started by the tracer, which releases it when
+// the span ends.
+func (t *QueryTracer) own(span trace.Span) {
+	if t.MaxEvents > 0 && span.IsRecording() {
+		t.events.Store(span, &atomic.Int64{})
+	}
+}
+
+// release forgets the events of the ended span.
+func (t *QueryTracer) release(span trace.Span) {
+	if t.MaxEvents > 0 {
+		t.events.Delete(span)
+	}
+}
+
+// truncate truncates the string values longer than MaxAttributeBytes in place.
+func (t *QueryTracer) truncate(attrs []attribute.KeyValue) {
+	if t.MaxAttributeBytes <= 0 {
+		return
+	}
+
+	for index, attr := range attrs {
+		if attr.Value.Type() != attribute.STRING {
+			continue
+		}
+
+		if value, ok := t.clip(attr.Value.AsString()); ok {
+			attrs[index] = attr.Key.String(value)
+		}
+	}
+}
+
+// clip truncates the value to MaxAttributeBytes, TruncationMarker included, and
+// reports whether it was truncated. The marker is omitted when it does not fit.
+func (t *QueryTracer) clip(value string) (string, bool) {
+	if t.MaxAttributeBytes <= 0 || len(value) <= t.MaxAttributeBytes {
+		return value, false
+	}
+
+	size := t.MaxAttributeBytes - len(TruncationMarker)
+	marker := TruncationMarker
+	if size <= 0 {
+		size, marker = t.MaxAttributeBytes, ""
+	}
+	// do not split a rune
+	for size > 0 && !utf8.RuneStart(value[size]) {
+		size--
+	}
+
+	t.recordLimited(limitAttributeBytes)
+	// done!
+	return value[:size] + marker, true
+}
+
+// exception records the error as an exception event, with the message truncated
+// like the attribute values.
+func (t *QueryTracer) exception(span trace.Span, err error) {
+	kind := err
+	// the wrapped error keeps the type of the joined error
+	if w, ok := err.(*wrappedError); ok {
+		kind = w.err
+	}
+
+	message, ok := t.clip(err.Error())
+	if !ok && kind == err {
+		span.RecordError(err)
+		return
+	}
+
+	attrs := []attribute.KeyValue{}
+	attrs = append(attrs, semconv.ExceptionType(errorType(kind)))
+	attrs = append(attrs, semconv.ExceptionMessage(message))
+
+	span.AddEvent(semconv.ExceptionEventName, trace.WithAttributes(attrs...))
+}
+
+// errorType returns the exception type of the error, like the SDK does.
+func errorType(err error) string {
+	kind := reflect.TypeOf(err)
+	if kind.PkgPath() == "" && kind.Name() == "" {
+		return kind.String()
+	}
+
+	return fmt.Sprintf("%s.%s", kind.PkgPath(), kind.Name())
+}
+
+func (t *QueryTracer) recordLimited(options metric.AddOption) {
+	if !t.Metrics {
+		return
+	}
+
+	if t.PoolName != "" {
+		t.instruments().limited.Add(context.Background(), 1, options, metric.WithAttributes(t.poolName()...))
+		return
+	}
+
+	t.instruments().limited.Add(context.Background(), 1, options)
+}
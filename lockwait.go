@@ -0,0 +1,145 @@
+package pgxotel
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	pgxpool "github.com/jackc/pgx/v5/pgxpool"
+	otel "go.opentelemetry.io/otel"
+	attribute "go.opentelemetry.io/otel/attribute"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// LockRelationKey is the attribute key for the relation a query waits to lock.
+	LockRelationKey = attribute.Key("db.postgresql.lock.relation")
+	// LockModeKey is the attribute key for the lock mode a query waits for.
+	LockModeKey = attribute.Key("db.postgresql.lock.mode")
+	// LockWaitTimeKey is the attribute key for the time in seconds a query waited
+	// when its locks were diagnosed.
+	LockWaitTimeKey = attribute.Key("db.postgresql.lock.wait_time")
+	// BlockingPIDKey is the attribute key for the process ID of the backend that
+	// holds the lock a query waits for.
+	BlockingPIDKey = attribute.Key("db.postgresql.lock.blocking_pid")
+	// BlockingStateKey is the attribute key for the state of the blocking backend.
+	BlockingStateKey = attribute.Key("db.postgresql.lock.blocking_state")
+	// BlockingQueryKey is the attribute key for the sanitized query of the blocking
+	// backend.
+	BlockingQueryKey = attribute.Key("db.postgresql.lock.blocking_query")
+)
+
+// lockWaitKey is the connection custom data key of the lock diagnostics.
+const lockWaitKey = "pgxotel.lock_wait"
+
+// lockWaitQuery reads the locks a backend waits for and the backends that hold
+// them.
+const lockWaitQuery = `SELECT COALESCE(w.relation::regclass::text, ''), w.mode, b.pid,
+COALESCE(b.state, ''), COALESCE(b.query, '')
+FROM pg_locks w
+CROSS JOIN LATERAL unnest(pg_blocking_pids(w.pid)) AS blocking(pid)
+JOIN pg_stat_activity b ON b.pid = blocking.pid
+WHERE w.pid = $1 AND NOT w.granted`
+
+// lockWait diagnoses the locks of a slow query on a side connection. The results
+// are recorded on the span when the query ends, so that the span is only used by
+// the connection.
+type lockWait struct {
+	timer  Timer
+	cancel context.CancelFunc
+
+	mu    sync.Mutex
+	found [][]attribute.KeyValue
+}
+
+// watchLocks diagnoses the locks of the query once it runs longer than the
+// LockThreshold.
+func (t *QueryTracer) watchLocks(ctx context.Context, conn *pgx.Conn, span trace.Span) {
+	if t.LockDiagnostics == nil || t.LockThreshold <= 0 || !span.IsRecording() || t.Minimal {
+		return
+	}
+
+	// the diagnostics are not traced
+	ctx, cancel := context.WithCancel(untraced(context.WithoutCancel(ctx)))
+
+	w := &lockWait{cancel: cancel}
+	pid := conn.PgConn().PID()
+	start := t.clock().Now()
+
+	w.timer = t.clock().AfterFunc(t.LockThreshold, func() {
+		found, err := t.diagnose(ctx, t.LockDiagnostics, pid, t.since(start))
+		if err != nil {
+			if ctx.Err() == nil {
+				otel.Handle(err)
+			}
+			return
+		}
+
+		w.mu.Lock()
+		w.found = found
+		w.mu.Unlock()
+	})
+
+	conn.PgConn().CustomData()[lockWaitKey] = w
+}
+
+// unwatchLocks stops the diagnostics of the query and adds a LockWait event per
+// blocking backend found.
+func (t *QueryTracer) unwatchLocks(conn *pgx.Conn, span trace.Span) {
+	data := conn.PgConn().CustomData()
+
+	w, ok := data[lockWaitKey].(*lockWait)
+	if !ok {
+		return
+	}
+
+	delete(data, lockWaitKey)
+	w.timer.Stop()
+	w.cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, attrs := range w.found {
+		t.event(span, "LockWait", attrs...)
+	}
+}
+
+func (t *QueryTracer) diagnose(ctx context.Context, pool *pgxpool.Pool, pid uint32, wait time.Duration) ([][]attribute.KeyValue, error) {
+	rows, err := pool.Query(ctx, lockWaitQuery, pid)
+	if err != nil {
+		return nil, err
+	}
+	// close the rows
+	defer rows.Close()
+
+	found := [][]attribute.KeyValue{}
+
+	for rows.Next() {
+		var (
+			relation, mode, state, query string
+			blocking                     int32
+		)
+
+		if err := rows.Scan(&relation, &mode, &blocking, &state, &query); err != nil {
+			return nil, err
+		}
+
+		attrs := []attribute.KeyValue{}
+		attrs = append(attrs, LockWaitTimeKey.Float64(wait.Seconds()))
+		attrs = append(attrs, LockModeKey.String(mode))
+		if relation != "" {
+			attrs = append(attrs, LockRelationKey.String(relation))
+		}
+		attrs = append(attrs, BlockingPIDKey.Int(int(blocking)))
+		attrs = append(attrs, BlockingStateKey.String(state))
+		if query != "" {
+			attrs = append(attrs, BlockingQueryKey.String(t.sanitize(query).Value.AsString()))
+		}
+
+		found = append(found, attrs)
+	}
+
+	return found, rows.Err()
+}
@@ -0,0 +1,156 @@
+package pgxotel
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	tracelog "github.com/jackc/pgx/v5/tracelog"
+	attribute "go.opentelemetry.io/otel/attribute"
+	log "go.opentelemetry.io/otel/log"
+	global "go.opentelemetry.io/otel/log/global"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// LogLevelKey is the attribute key for the level of a tracelog entry.
+	LogLevelKey = attribute.Key("pgx.log.level")
+	// LogMessageKey is the attribute key for the message of a tracelog entry.
+	LogMessageKey = attribute.Key("pgx.log.message")
+	// LogKeyPrefix is the prefix of the attribute keys for the data of a tracelog
+	// entry.
+	LogKeyPrefix = "pgx.log."
+)
+
+// Logger returns a tracelog.Logger that records the log entries as Log events of
+// the active span, or as OpenTelemetry log records when no span is recording.
+// The TraceLog must precede the QueryTracer in a multitracer.Tracer, so that the
+// spans are still recording when the entries are logged. The arguments of the
+// queries are not recorded.
+func (t *QueryTracer) Logger() tracelog.Logger {
+	return tracelog.LoggerFunc(t.log)
+}
+
+func (t *QueryTracer) log(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]any) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		// the arguments carry the values of the parameters
+		if key != "args" {
+			keys = append(keys, key)
+		}
+	}
+	// the attributes are ordered
+	slices.Sort(keys)
+
+	span := trace.SpanFromContext(ctx)
+	if span.IsRecording() && !t.DisableSpans {
+		attrs := make([]attribute.KeyValue, 0, 2+len(keys))
+		attrs = append(attrs, LogLevelKey.String(level.String()))
+		attrs = append(attrs, LogMessageKey.String(msg))
+		for _, key := range keys {
+			attrs = append(attrs, t.logAttribute(key, data[key]))
+		}
+		attrs = stabilize(t.compatible(attrs))
+
+		t.event(span, "Log", attrs...)
+		return
+	}
+
+	record := log.Record{}
+	record.SetTimestamp(t.clock().Now())
+	record.SetSeverity(severity(level))
+	record.SetSeverityText(level.String())
+	record.SetBody(log.StringValue(msg))
+	attrs := make([]attribute.KeyValue, 0, len(keys))
+	for _, key := range keys {
+		attrs = append(attrs, t.logAttribute(key, data[key]))
+	}
+	t.truncate(attrs)
+
+	for _, attr := range attrs {
+		record.AddAttributes(log.KeyValue{Key: string(attr.Key), Value: logValue(attr.Value)})
+	}
+
+	t.logger().Emit(ctx, record)
+}
+
+func (t *QueryTracer) logger() log.Logger {
+	options := []log.LoggerOption{}
+	if version := t.Version; version != "" {
+		options = append(options, log.WithInstrumentationVersion(version))
+	} else if version := moduleVersion(); version != "" && version != "(devel)" {
+		options = append(options, log.WithInstrumentationVersion(version))
+	}
+
+	provider := t.LoggerProvider
+	if provider == nil {
+		provider = global.GetLoggerProvider()
+	}
+
+	return provider.Logger(t.Name, options...)
+}
+
+// logAttribute converts the data of a tracelog entry to an attribute. The
+// statement is sanitized like the db.statement attribute.
+func (t *QueryTracer) logAttribute(key string, value any) attribute.KeyValue {
+	name := attribute.Key(LogKeyPrefix + key)
+
+	switch value := value.(type) {
+	case string:
+		if key == "sql" {
+			return t.statement(value)
+		}
+		return name.String(value)
+	case bool:
+		return name.Bool(value)
+	case int:
+		return name.Int(value)
+	case int32:
+		return name.Int64(int64(value))
+	case int64:
+		return name.Int64(value)
+	case uint32:
+		return name.Int64(int64(value))
+	case time.Duration:
+		return name.Float64(value.Seconds())
+	case error:
+		return name.String(value.Error())
+	case fmt.Stringer:
+		return name.String(value.String())
+	default:
+		return name.String(fmt.Sprint(value))
+	}
+}
+
+// logValue converts the value of an attribute to a log value.
+func logValue(value attribute.Value) log.Value {
+	switch value.Type() {
+	case attribute.BOOL:
+		return log.BoolValue(value.AsBool())
+	case attribute.INT64:
+		return log.Int64Value(value.AsInt64())
+	case attribute.FLOAT64:
+		return log.Float64Value(value.AsFloat64())
+	default:
+		return log.StringValue(value.Emit())
+	}
+}
+
+// severity maps the tracelog level to the log severity.
+func severity(level tracelog.LogLevel) log.Severity {
+	switch level {
+	case tracelog.LogLevelTrace:
+		return log.SeverityTrace
+	case tracelog.LogLevelDebug:
+		return log.SeverityDebug
+	case tracelog.LogLevelInfo:
+		return log.SeverityInfo
+	case tracelog.LogLevelWarn:
+		return log.SeverityWarn
+	case tracelog.LogLevelError:
+		return log.SeverityError
+	default:
+		return log.SeverityUndefined
+	}
+}
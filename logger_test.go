@@ -0,0 +1,45 @@
+package pgxotel_test
+
+import (
+	"context"
+	"os"
+
+	"github.com/jackc/pgx/v5/multitracer"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/tracelog"
+	"github.com/pgx-contrib/pgxotel"
+)
+
+func ExampleQueryTracer_Logger() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	tracer := &pgxotel.QueryTracer{
+		Name: "example-api",
+	}
+
+	// the log entries are recorded on the spans of the tracer
+	config.ConnConfig.Tracer = multitracer.New(
+		&tracelog.TraceLog{
+			Logger:   tracer.Logger(),
+			LogLevel: tracelog.LogLevelDebug,
+		},
+		tracer,
+	)
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the pool
+	defer pool.Close()
+
+	rows, err := pool.Query(context.TODO(), "SELECT * from customer")
+	if err != nil {
+		panic(err)
+	}
+	// close the rows
+	defer rows.Close()
+}
@@ -0,0 +1,33 @@
+package pgxotel
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// memoCapacity is the maximum number of values cached by a memo.
+const memoCapacity = 1024
+
+// memo caches the values derived from the queries, since applications usually
+// issue the same statements over and over again.
+type memo[V any] struct {
+	entries sync.Map
+	size    atomic.Int64
+}
+
+// get returns the value of the query, computed by fn on a cache miss.
+func (m *memo[V]) get(query string, fn func(string) V) V {
+	if value, ok := m.entries.Load(query); ok {
+		return value.(V)
+	}
+
+	value := fn(query)
+	// the cache stops growing when it is full
+	if m.size.Load() < memoCapacity {
+		if _, loaded := m.entries.LoadOrStore(query, value); !loaded {
+			m.size.Add(1)
+		}
+	}
+
+	return value
+}
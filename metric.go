@@ -0,0 +1,425 @@
+package pgxotel
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	otel "go.opentelemetry.io/otel"
+	attribute "go.opentelemetry.io/otel/attribute"
+	metric "go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+// instruments holds the metric instruments of a QueryTracer.
+type instruments struct {
+	rows      metric.Int64Histogram
+	errors    metric.Int64Counter
+	acquire   metric.Float64Histogram
+	copied    metric.Int64Counter
+	copy      metric.Float64Histogram
+	active    metric.Int64UpDownCounter
+	deadlocks metric.Int64Counter
+	conflicts metric.Int64Counter
+	limited   metric.Int64Counter
+	hits      metric.Int64Counter
+	misses    metric.Int64Counter
+	evictions metric.Int64Counter
+	invalid   metric.Int64Counter
+	cached    metric.Int64UpDownCounter
+	attempts  metric.Int64Counter
+	connected metric.Int64Counter
+	failures  metric.Int64Counter
+	connect   metric.Float64Histogram
+	lifetime  metric.Float64Histogram
+	up        metric.Int64Gauge
+	success   metric.Int64Gauge
+}
+
+func (t *QueryTracer) meter() metric.Meter {
+	provider := t.MeterProvider
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+	// get the meter
+	return provider.Meter(t.Name)
+}
+
+func (t *QueryTracer) instruments() *instruments {
+	t.once.Do(func() {
+		meter := t.meter()
+
+		var err error
+
+		t.metrics = &instruments{}
+		t.metrics.rows, err = meter.Int64Histogram("db.client.response.returned_rows",
+			metric.WithDescription("The number of rows returned by the operation."),
+			metric.WithUnit("{row}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.errors, err = meter.Int64Counter("db.client.operation.errors",
+			metric.WithDescription("The number of failed operations."),
+			metric.WithUnit("{error}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.acquire, err = meter.Float64Histogram("db.client.connection.wait_time",
+			metric.WithDescription("The time it took to acquire a connection from the pool."),
+			metric.WithUnit("s"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.copied, err = meter.Int64Counter("db.client.copy.rows",
+			metric.WithDescription("The number of rows copied by CopyFrom."),
+			metric.WithUnit("{row}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.copy, err = meter.Float64Histogram("db.client.copy.duration",
+			metric.WithDescription("The duration of CopyFrom operations."),
+			metric.WithUnit("s"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.active, err = meter.Int64UpDownCounter("db.client.operations.active",
+			metric.WithDescription("The number of operations in flight."),
+			metric.WithUnit("{operation}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.deadlocks, err = meter.Int64Counter("db.client.deadlocks",
+			metric.WithDescription("The number of operations aborted by a deadlock."),
+			metric.WithUnit("{deadlock}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.conflicts, err = meter.Int64Counter("db.client.serialization_failures",
+			metric.WithDescription("The number of operations aborted by a serialization failure."),
+			metric.WithUnit("{failure}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.limited, err = meter.Int64Counter("db.client.telemetry.limited",
+			metric.WithDescription("The number of events dropped and attribute values truncated by the tracer limits."),
+			metric.WithUnit("{item}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.hits, err = meter.Int64Counter("db.client.statement_cache.hits",
+			metric.WithDescription("The number of queries that found their statement in the pgx cache."),
+			metric.WithUnit("{query}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.misses, err = meter.Int64Counter("db.client.statement_cache.misses",
+			metric.WithDescription("The number of queries that prepared their statement on a pgx cache miss."),
+			metric.WithUnit("{query}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.evictions, err = meter.Int64Counter("db.client.statement_cache.evictions",
+			metric.WithDescription("The estimated number of statements evicted from the pgx cache."),
+			metric.WithUnit("{statement}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.invalid, err = meter.Int64Counter("db.client.statement_cache.invalidations",
+			metric.WithDescription("The number of statements invalidated in the pgx cache after a failed query."),
+			metric.WithUnit("{statement}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.cached, err = meter.Int64UpDownCounter("db.client.statement_cache.size",
+			metric.WithDescription("The estimated number of statements in the pgx caches of the connections."),
+			metric.WithUnit("{statement}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.attempts, err = meter.Int64Counter("db.client.connection.attempts",
+			metric.WithDescription("The number of connection attempts."),
+			metric.WithUnit("{attempt}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.connected, err = meter.Int64Counter("db.client.connection.successes",
+			metric.WithDescription("The number of connections established."),
+			metric.WithUnit("{connection}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.failures, err = meter.Int64Counter("db.client.connection.failures",
+			metric.WithDescription("The number of failed connection attempts."),
+			metric.WithUnit("{attempt}"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.connect, err = meter.Float64Histogram("db.client.connection.create_time",
+			metric.WithDescription("The time it took to establish a connection."),
+			metric.WithUnit("s"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.lifetime, err = meter.Float64Histogram("db.client.connection.lifetime",
+			metric.WithDescription("The time a connection was open until it was closed."),
+			metric.WithUnit("s"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.up, err = meter.Int64Gauge("db.client.health.up",
+			metric.WithDescription("Whether the last health probe succeeded (1) or failed (0)."),
+			metric.WithUnit("1"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+
+		t.metrics.success, err = meter.Int64Gauge("db.client.health.last_success",
+			metric.WithDescription("The Unix time of the last successful health probe."),
+			metric.WithUnit("s"),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+	})
+
+	return t.metrics
+}
+
+func (t *QueryTracer) recordRows(ctx context.Context, conn *pgx.Conn, rows int64) {
+	if !t.Metrics {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBName(t.cache(conn).config.Database),
+		semconv.DBOperation("SELECT"),
+	}
+	attrs = stabilize(attrs)
+	attrs = append(attrs, t.tenant(ctx)...)
+	attrs = append(attrs, t.poolName()...)
+	t.instruments().rows.Record(ctx, rows, metric.WithAttributes(attrs...))
+}
+
+func (t *QueryTracer) recordAcquire(ctx context.Context, database string, duration time.Duration) {
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBName(database),
+	}
+	attrs = stabilize(attrs)
+	attrs = append(attrs, t.tenant(ctx)...)
+	attrs = append(attrs, t.poolName()...)
+	t.instruments().acquire.Record(ctx, duration.Seconds(), metric.WithAttributes(attrs...))
+}
+
+func (t *QueryTracer) recordCopy(ctx context.Context, rows int64) {
+	op := operationFrom(ctx)
+	if op == nil || !t.Metrics {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBName(op.database),
+		semconv.DBSQLTable(op.table),
+	}
+	attrs = stabilize(attrs)
+	attrs = append(attrs, t.tenant(ctx)...)
+	attrs = append(attrs, t.poolName()...)
+	options := metric.WithAttributes(attrs...)
+
+	t.instruments().copied.Add(ctx, rows, options)
+	t.instruments().copy.Record(ctx, t.since(op.start).Seconds(), options)
+}
+
+// recordActive adds delta to the number of operations in flight.
+func (t *QueryTracer) recordActive(ctx context.Context, delta int64) {
+	op := operationFrom(ctx)
+	if op == nil || !t.Metrics {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBName(op.database),
+		semconv.DBOperation(op.name),
+	}
+	attrs = stabilize(attrs)
+	attrs = append(attrs, t.tenant(ctx)...)
+	attrs = append(attrs, t.poolName()...)
+	t.instruments().active.Add(ctx, delta, metric.WithAttributes(attrs...))
+}
+
+func (t *QueryTracer) recordError(ctx context.Context, err error) {
+	op := operationFrom(ctx)
+	if op == nil || !t.Metrics {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBName(op.database),
+		semconv.DBOperation(op.name),
+		SQLStateClassKey.String(sqlStateClass(err)),
+	}
+	attrs = stabilize(attrs)
+	attrs = append(attrs, t.tenant(ctx)...)
+	attrs = append(attrs, t.poolName()...)
+	t.instruments().errors.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+func (t *QueryTracer) recordDeadlock(ctx context.Context) {
+	op := operationFrom(ctx)
+	if op == nil || !t.Metrics {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBName(op.database),
+		semconv.DBOperation(op.name),
+	}
+	attrs = stabilize(attrs)
+	attrs = append(attrs, t.tenant(ctx)...)
+	attrs = append(attrs, t.poolName()...)
+	t.instruments().deadlocks.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+func (t *QueryTracer) recordSerializationFailure(ctx context.Context) {
+	op := operationFrom(ctx)
+	if op == nil || !t.Metrics {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBName(op.database),
+		semconv.DBOperation(op.name),
+	}
+	if op.table != "" {
+		attrs = append(attrs, semconv.DBSQLTable(op.table))
+	}
+	attrs = stabilize(attrs)
+	attrs = append(attrs, t.tenant(ctx)...)
+	attrs = append(attrs, t.poolName()...)
+	t.instruments().conflicts.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+// recordConnect records the connection attempt that ended.
+func (t *QueryTracer) recordConnect(ctx context.Context, err error) {
+	op := operationFrom(ctx)
+	if op == nil || !t.Metrics {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBName(op.database),
+	}
+	attrs = stabilize(attrs)
+	attrs = append(attrs, t.tenant(ctx)...)
+	attrs = append(attrs, t.poolName()...)
+	options := metric.WithAttributes(attrs...)
+
+	metrics := t.instruments()
+	metrics.attempts.Add(ctx, 1, options)
+	metrics.connect.Record(ctx, t.since(op.start).Seconds(), options)
+
+	if err == nil {
+		metrics.connected.Add(ctx, 1, options)
+		return
+	}
+
+	attrs = append(attrs, ErrorTypeKey.String(connectErrorType(err)))
+	metrics.failures.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+// connectErrorType returns the class of a connection error: auth, timeout,
+// refused or other.
+func connectErrorType(err error) string {
+	var perr *pgconn.PgError
+	var nerr net.Error
+
+	switch {
+	case errors.As(err, &perr) && strings.HasPrefix(perr.Code, "28"):
+		// invalid_authorization_specification and invalid_password
+		return "auth"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &nerr) && nerr.Timeout():
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	default:
+		return "other"
+	}
+}
+
+// sqlStateClass returns the SQLSTATE class of the error.
+func sqlStateClass(err error) string {
+	var perr *pgconn.PgError
+	if errors.As(err, &perr) && len(perr.Code) >= 2 {
+		return perr.Code[:2]
+	}
+
+	return "_OTHER"
+}
+
+const (
+	// deadlockDetected is the SQLSTATE of deadlock_detected errors.
+	deadlockDetected = "40P01"
+	// serializationFailure is the SQLSTATE of serialization_failure errors.
+	serializationFailure = "40001"
+)
+
+// isCode reports whether err is a PgError with the given SQLSTATE.
+func isCode(err error, code string) bool {
+	if err == nil {
+		return false
+	}
+
+	var perr *pgconn.PgError
+	return errors.As(err, &perr) && perr.Code == code
+}
@@ -0,0 +1,16 @@
+//go:build !pgxotel_debug
+
+package pgxotel
+
+import (
+	attribute "go.opentelemetry.io/otel/attribute"
+)
+
+// Debug reports whether the binary is built with the pgxotel_debug build tag.
+const Debug = false
+
+// values never records the values of the bind parameters without the
+// pgxotel_debug build tag.
+func (t *QueryTracer) values(args []any) []attribute.KeyValue {
+	return nil
+}
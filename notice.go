@@ -0,0 +1,46 @@
+package pgxotel
+
+import (
+	pgx "github.com/jackc/pgx/v5"
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	attribute "go.opentelemetry.io/otel/attribute"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// NoticeSeverityKey is the attribute key for the severity of a notice.
+	NoticeSeverityKey = attribute.Key("db.postgresql.notice.severity")
+	// NoticeCodeKey is the attribute key for the SQLSTATE code of a notice.
+	NoticeCodeKey = attribute.Key("db.postgresql.notice.code")
+	// NoticeMessageKey is the attribute key for the message of a notice.
+	NoticeMessageKey = attribute.Key("db.postgresql.notice.message")
+)
+
+// spanKey is the connection custom data key of the active span.
+const spanKey = "pgxotel.span"
+
+// OnNotice records the notice as an event on the active span of the connection.
+// It can be used as pgconn.Config.OnNotice.
+func (t *QueryTracer) OnNotice(conn *pgconn.PgConn, notice *pgconn.Notice) {
+	span, ok := conn.CustomData()[spanKey].(trace.Span)
+	if !ok {
+		return
+	}
+
+	attrs := []attribute.KeyValue{}
+	attrs = append(attrs, NoticeSeverityKey.String(notice.Severity))
+	attrs = append(attrs, NoticeCodeKey.String(notice.Code))
+	attrs = append(attrs, NoticeMessageKey.String(notice.Message))
+
+	t.event(span, "Notice", attrs...)
+}
+
+// attach makes the span the active span of the connection.
+func (t *QueryTracer) attach(conn *pgx.Conn, span trace.Span) {
+	conn.PgConn().CustomData()[spanKey] = span
+}
+
+// detach clears the active span of the connection.
+func (t *QueryTracer) detach(conn *pgx.Conn) {
+	delete(conn.PgConn().CustomData(), spanKey)
+}
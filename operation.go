@@ -0,0 +1,283 @@
+package pgxotel
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+// Operation describes a traced operation that ended (see QueryTracer.OnEnd).
+type Operation struct {
+	// Type is the type of the operation
+	Type OperationType
+	// Name is the name of the operation (e.g. SELECT)
+	Name string
+	// SQL is the statement of the operation, if any
+	SQL string
+	// Database is the name of the database
+	Database string
+	// Table is the name of the table, if known
+	Table string
+	// CommandTag is the command tag of the operation
+	CommandTag pgconn.CommandTag
+	// Start is the time the operation started
+	Start time.Time
+	// Duration is the duration of the operation
+	Duration time.Duration
+}
+
+// operation describes a traced operation carried in the context.
+type operation struct {
+	// kind is the type of the operation
+	kind OperationType
+	// database is the name of the database
+	database string
+	// name is the name of the operation (e.g. SELECT)
+	name string
+	// sql is the statement of the operation, if any
+	sql string
+	// table is the name of the table, if known
+	table string
+	// start is the time the operation started
+	start time.Time
+}
+
+type operationKey struct{}
+
+// names are the names of the operations that do not have a statement.
+var names = map[OperationType]string{
+	OperationConnect:  "CONNECT",
+	OperationPrepare:  "PREPARE",
+	OperationBatch:    "BATCH",
+	OperationCopyFrom: "COPY",
+}
+
+// begin stores the operation of the given type and statement on the database in
+// the context.
+func (t *QueryTracer) begin(ctx context.Context, kind OperationType, database string, sql string) context.Context {
+	if !t.Metrics && t.OnEnd == nil {
+		return ctx
+	}
+
+	op := &operation{
+		kind:     kind,
+		database: database,
+		name:     names[kind],
+		sql:      sql,
+		start:    t.clock().Now(),
+	}
+
+	if op.name == "" {
+		op.name = OperationFromSQL(sql)
+	}
+
+	if sql != "" {
+		op.table = t.tables.get(sql, tableName)
+	}
+
+	return context.WithValue(ctx, operationKey{}, op)
+}
+
+// end calls the OnEnd hook of the operation of the context, if any.
+func (t *QueryTracer) end(ctx context.Context, span trace.Span, tag pgconn.CommandTag, err error) {
+	if t.OnEnd == nil {
+		return
+	}
+
+	op := operationFrom(ctx)
+	if op == nil {
+		return
+	}
+
+	if span == nil {
+		span = trace.SpanFromContext(context.Background())
+	}
+
+	t.OnEnd(ctx, Operation{
+		Type:       op.kind,
+		Name:       op.name,
+		SQL:        op.sql,
+		Database:   op.database,
+		Table:      op.table,
+		CommandTag: tag,
+		Start:      op.start,
+		Duration:   t.since(op.start),
+	}, err, span)
+}
+
+func operationFrom(ctx context.Context) *operation {
+	op, _ := ctx.Value(operationKey{}).(*operation)
+	return op
+}
+
+// OperationFromSQL returns the operation name recorded as db.operation for the
+// statement: its uppercase leading keyword (e.g. SELECT or CREATE), after the
+// comments and the opening parentheses, or UNKNOWN.
+func OperationFromSQL(query string) string {
+	for len(query) > 0 {
+		switch {
+		case strings.HasPrefix(query, "--"):
+			if index := strings.IndexByte(query, '\n'); index >= 0 {
+				query = query[index+1:]
+			} else {
+				query = ""
+			}
+		case strings.HasPrefix(query, "/*"):
+			if index := strings.Index(query, "*/"); index >= 0 {
+				query = query[index+2:]
+			} else {
+				query = ""
+			}
+		case query[0] == '(' || unicode.IsSpace(rune(query[0])):
+			query = query[1:]
+		default:
+			index := strings.IndexFunc(query, func(r rune) bool {
+				return !unicode.IsLetter(r)
+			})
+
+			if index < 0 {
+				index = len(query)
+			}
+
+			if index == 0 {
+				return "UNKNOWN"
+			}
+
+			return strings.ToUpper(query[:index])
+		}
+	}
+
+	return "UNKNOWN"
+}
+
+// OperationFromCommandTag returns the operation name of the command tag,
+// classified like OperationFromSQL. The db.operation attribute of the command
+// tags is only SELECT, INSERT, UPDATE, DELETE or UNKNOWN.
+func OperationFromCommandTag(tag pgconn.CommandTag) string {
+	return OperationFromSQL(tag.String())
+}
+
+// tableName returns the primary table of the query on a best-effort basis: the
+// table after the first FROM, INTO or UPDATE keyword outside of parentheses.
+func tableName(query string) string {
+	depth := 0
+	keyword := false
+
+	for len(query) > 0 {
+		var token string
+		// scan the next token
+		c, size := utf8.DecodeRuneInString(query)
+		switch {
+		case strings.HasPrefix(query, "--"):
+			if index := strings.IndexByte(query, '\n'); index >= 0 {
+				query = query[index+1:]
+			} else {
+				query = ""
+			}
+			continue
+		case strings.HasPrefix(query, "/*"):
+			if index := strings.Index(query, "*/"); index >= 0 {
+				query = query[index+2:]
+			} else {
+				query = ""
+			}
+			continue
+		case unicode.IsSpace(c):
+			query = query[size:]
+			continue
+		case c == '\'':
+			index := strings.IndexByte(query[1:], '\'')
+			if index < 0 {
+				return ""
+			}
+			query = query[index+2:]
+			keyword = false
+			continue
+		case c == '(':
+			if keyword {
+				// subqueries do not have a name
+				return ""
+			}
+			depth++
+			query = query[1:]
+			continue
+		case c == ')':
+			depth--
+			query = query[1:]
+			continue
+		case c == '"' || c == '_' || unicode.IsLetter(c):
+			rest := ""
+			if token, rest = identifier(query); len(rest) == len(query) {
+				// always move forward
+				rest = query[size:]
+			}
+			query = rest
+		default:
+			query = query[size:]
+			keyword = false
+			continue
+		}
+
+		if depth > 0 {
+			continue
+		}
+
+		if keyword {
+			if strings.EqualFold(token, "ONLY") {
+				continue
+			}
+
+			return token
+		}
+
+		switch strings.ToUpper(token) {
+		case "FROM", "INTO", "UPDATE":
+			keyword = true
+		}
+	}
+
+	return ""
+}
+
+// identifier scans the possibly quoted and qualified identifier at the start of
+// the query.
+func identifier(query string) (string, string) {
+	builder := &strings.Builder{}
+
+	for len(query) > 0 {
+		if query[0] == '"' {
+			index := strings.IndexByte(query[1:], '"')
+			if index < 0 {
+				return "", ""
+			}
+
+			builder.WriteString(query[1 : index+1])
+			query = query[index+2:]
+		} else {
+			index := strings.IndexFunc(query, func(r rune) bool {
+				return r != '_' && r != '$' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			})
+
+			if index < 0 {
+				index = len(query)
+			}
+
+			builder.WriteString(query[:index])
+			query = query[index:]
+		}
+
+		if len(query) < 2 || query[0] != '.' {
+			break
+		}
+
+		builder.WriteByte('.')
+		query = query[1:]
+	}
+
+	return builder.String(), query
+}
@@ -0,0 +1,524 @@
+package pgxotel
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	pgxpool "github.com/jackc/pgx/v5/pgxpool"
+	attribute "go.opentelemetry.io/otel/attribute"
+	log "go.opentelemetry.io/otel/log"
+	metric "go.opentelemetry.io/otel/metric"
+	propagation "go.opentelemetry.io/otel/propagation"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+// Option configures a QueryTracer.
+type Option func(*QueryTracer)
+
+// NewQueryTracer creates a QueryTracer with the given name and options.
+func NewQueryTracer(name string, opts ...Option) *QueryTracer {
+	t := &QueryTracer{Name: name}
+	// apply the options
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// WithName sets the name of the tracer.
+func WithName(name string) Option {
+	return func(t *QueryTracer) {
+		t.Name = name
+	}
+}
+
+// WithTracerProvider sets the provider of the tracer instead of the global one.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(t *QueryTracer) {
+		t.TracerProvider = provider
+	}
+}
+
+// WithMeterProvider sets the provider of the meter instead of the global one.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(t *QueryTracer) {
+		t.MeterProvider = provider
+	}
+}
+
+// WithLoggerProvider sets the provider of the logger of Logger instead of the
+// global one.
+func WithLoggerProvider(provider log.LoggerProvider) Option {
+	return func(t *QueryTracer) {
+		t.LoggerProvider = provider
+	}
+}
+
+// WithTracerOptions sets the options provided to the tracer.
+func WithTracerOptions(opts ...trace.TracerOption) Option {
+	return func(t *QueryTracer) {
+		t.Options = append(t.Options, opts...)
+	}
+}
+
+// WithAttributes records the attributes on every span.
+func WithAttributes(attrs ...attribute.KeyValue) Option {
+	return func(t *QueryTracer) {
+		t.Attributes = append(t.Attributes, attrs...)
+	}
+}
+
+// WithSpanStartOptions provides the options to the spans of the given operation
+// type (e.g. links, kinds or attributes).
+func WithSpanStartOptions(operation OperationType, opts ...trace.SpanStartOption) Option {
+	return func(t *QueryTracer) {
+		if t.SpanStartOptions == nil {
+			t.SpanStartOptions = map[OperationType][]trace.SpanStartOption{}
+		}
+
+		t.SpanStartOptions[operation] = append(t.SpanStartOptions[operation], opts...)
+	}
+}
+
+// WithoutOperations disables the spans of the given operation types (e.g.
+// OperationPrepare) while keeping the others.
+func WithoutOperations(operations ...OperationType) Option {
+	return func(t *QueryTracer) {
+		t.DisabledOperations = append(t.DisabledOperations, operations...)
+	}
+}
+
+// WithTraceIDParameter propagates the trace ID via the given run-time parameter,
+// updating it at most once per interval on the same connection. Within the
+// interval, the connection reports the trace ID of the previous update.
+func WithTraceIDParameter(name string, interval time.Duration) Option {
+	return func(t *QueryTracer) {
+		t.TraceIDParameter = name
+		t.TraceIDInterval = interval
+	}
+}
+
+// WithTraceParentParameter propagates the W3C traceparent via the given
+// transaction-local run-time parameter in transactions started by BeginTx.
+func WithTraceParentParameter(name string) Option {
+	return func(t *QueryTracer) {
+		t.TraceParentParameter = name
+	}
+}
+
+// WithMetrics enables the metric instruments.
+func WithMetrics() Option {
+	return func(t *QueryTracer) {
+		t.Metrics = true
+	}
+}
+
+// WithMetricsOnly enables the metric instruments and disables all the spans, for
+// services that only need the metrics.
+func WithMetricsOnly() Option {
+	return func(t *QueryTracer) {
+		t.Metrics = true
+		t.DisableSpans = true
+	}
+}
+
+// WithRootSpans creates the Close spans of BeforeClose and the Probe spans of
+// Monitor as root spans.
+func WithRootSpans() Option {
+	return func(t *QueryTracer) {
+		t.RootSpans = true
+	}
+}
+
+// WithDeferredSpans creates the spans when the operations end, which records their
+// exact start and end times and allows dropping them depending on the outcome.
+func WithDeferredSpans() Option {
+	return func(t *QueryTracer) {
+		t.DeferredSpans = true
+	}
+}
+
+// WithLockDiagnostics diagnoses the locks of the queries that run longer than the
+// threshold with the connections of the pool. A small dedicated pool keeps the
+// diagnostics working when the application pool is exhausted.
+func WithLockDiagnostics(pool *pgxpool.Pool, threshold time.Duration) Option {
+	return func(t *QueryTracer) {
+		t.LockDiagnostics = pool
+		t.LockThreshold = threshold
+	}
+}
+
+// WithErrorsOnly emits only the spans of the operations that fail or, when the
+// threshold is positive, take at least the threshold.
+func WithErrorsOnly(threshold time.Duration) Option {
+	return func(t *QueryTracer) {
+		t.ErrorsOnly = true
+		t.SlowThreshold = threshold
+	}
+}
+
+// WithMinimumSpanDuration drops the spans of the successful operations that are
+// faster than the duration (e.g. primary key lookups). Failed operations are
+// always traced.
+func WithMinimumSpanDuration(duration time.Duration) Option {
+	return func(t *QueryTracer) {
+		t.MinimumSpanDuration = duration
+	}
+}
+
+// WithOkStatus sets the status of successful spans to Ok.
+func WithOkStatus() Option {
+	return func(t *QueryTracer) {
+		t.OkStatus = true
+	}
+}
+
+// WithCancellation sets how context cancellations are recorded.
+func WithCancellation(mode CancellationMode) Option {
+	return func(t *QueryTracer) {
+		t.Cancellation = mode
+	}
+}
+
+// WithBenignErrors registers errors that end spans without the Error status.
+func WithBenignErrors(errs ...error) Option {
+	return func(t *QueryTracer) {
+		t.BenignErrors = append(t.BenignErrors, errs...)
+	}
+}
+
+// WithBenignCodes registers SQLSTATE codes that end spans without the Error status.
+func WithBenignCodes(codes ...string) Option {
+	return func(t *QueryTracer) {
+		t.BenignCodes = append(t.BenignCodes, codes...)
+	}
+}
+
+// WithStatusMapper sets the function that determines the status of every span.
+func WithStatusMapper(fn StatusMapper) Option {
+	return func(t *QueryTracer) {
+		t.StatusMapper = fn
+	}
+}
+
+// WithPgErrorEvent records the details of PgError errors as a span event, rewritten
+// by the optional redactor.
+func WithPgErrorEvent(redactor func(field, value string) string) Option {
+	return func(t *QueryTracer) {
+		t.PgErrorEvent = true
+		t.PgErrorRedactor = redactor
+	}
+}
+
+// WithParameterTypes records the Postgres type names of the bind parameters, which
+// reveals the type mismatches (e.g. text vs uuid) that change query plans. They
+// are only recorded in the exec and simple protocol modes (see ParameterTypes).
+func WithParameterTypes() Option {
+	return func(t *QueryTracer) {
+		t.ParameterTypes = true
+	}
+}
+
+// WithParameterValues records the values of the bind parameters. It only takes
+// effect in binaries built with the pgxotel_debug build tag, so that production
+// binaries cannot record them.
+func WithParameterValues() Option {
+	return func(t *QueryTracer) {
+		t.ParameterValues = true
+	}
+}
+
+// WithParameterNullMask records which bind parameters are null.
+func WithParameterNullMask() Option {
+	return func(t *QueryTracer) {
+		t.ParameterNullMask = true
+	}
+}
+
+// WithParameterNames records the names of named arguments (e.g. pgx.NamedArgs).
+func WithParameterNames() Option {
+	return func(t *QueryTracer) {
+		t.ParameterNames = true
+	}
+}
+
+// WithInstanceID sets the database instance identifier recorded on every span.
+func WithInstanceID(id string) Option {
+	return func(t *QueryTracer) {
+		t.InstanceID = id
+	}
+}
+
+// WithInstanceIDDiscovery discovers the database instance identifier when a
+// connection is established.
+func WithInstanceIDDiscovery() Option {
+	return func(t *QueryTracer) {
+		t.DiscoverInstanceID = true
+	}
+}
+
+// WithServerFactsDiscovery records the server version, the superuser flag and the
+// time zone of the connections, which are queried once when a connection is
+// established.
+func WithServerFactsDiscovery() Option {
+	return func(t *QueryTracer) {
+		t.DiscoverServerFacts = true
+	}
+}
+
+// WithServerVersion records the version of the server on every span.
+func WithServerVersion() Option {
+	return func(t *QueryTracer) {
+		t.ServerVersion = true
+	}
+}
+
+// WithPooler records the connection pooler and its pool mode (which may be empty)
+// on every span.
+func WithPooler(name, mode string) Option {
+	return func(t *QueryTracer) {
+		t.Pooler = name
+		t.PoolMode = mode
+	}
+}
+
+// WithPoolerDetection detects the connection pooler from the host name and the
+// port of the connections.
+func WithPoolerDetection() Option {
+	return func(t *QueryTracer) {
+		t.DetectPooler = true
+	}
+}
+
+// WithTenantFunc sets the function that returns the tenant recorded on every span
+// and metric.
+func WithTenantFunc(fn func(ctx context.Context) string) Option {
+	return func(t *QueryTracer) {
+		t.TenantFunc = fn
+	}
+}
+
+// WithRuntimeParams records the given run-time parameters of the connection config.
+func WithRuntimeParams(names ...string) Option {
+	return func(t *QueryTracer) {
+		t.RuntimeParams = append(t.RuntimeParams, names...)
+	}
+}
+
+// Minimal configures the tracer to record only the span name, duration, status and
+// db.system. The connection string, statement and events are omitted.
+func Minimal() Option {
+	return func(t *QueryTracer) {
+		t.Minimal = true
+	}
+}
+
+// WithoutConnectionString omits the db.connection_string attribute. Even masked,
+// the connection string reveals the hosts, ports, user and TLS settings.
+func WithoutConnectionString() Option {
+	return func(t *QueryTracer) {
+		t.OmitConnectionString = true
+	}
+}
+
+// WithUserRedactor rewrites the db.user attribute with the given function.
+func WithUserRedactor(fn func(user string) string) Option {
+	return func(t *QueryTracer) {
+		t.UserRedactor = fn
+	}
+}
+
+// WithHashedUser replaces the db.user attribute with an HMAC-SHA256 of the user
+// keyed by the salt, so that spans of the same user can still be correlated.
+func WithHashedUser(salt string) Option {
+	return WithUserRedactor(func(user string) string {
+		mac := hmac.New(sha256.New, []byte(salt))
+		mac.Write([]byte(user))
+		return hex.EncodeToString(mac.Sum(nil)[:8])
+	})
+}
+
+// WithUserPlaceholder replaces the db.user attribute with a static placeholder.
+func WithUserPlaceholder(placeholder string) Option {
+	return WithUserRedactor(func(string) string {
+		return placeholder
+	})
+}
+
+// WithStatementSanitizer sets the sanitizer of the statements recorded as
+// db.statement.
+func WithStatementSanitizer(sanitizer StatementSanitizer) Option {
+	return func(t *QueryTracer) {
+		t.Sanitizer = sanitizer
+	}
+}
+
+// WithStatementMode sets the StatementSanitizer of the mode.
+func WithStatementMode(mode StatementMode) Option {
+	return WithStatementSanitizer(mode.Sanitizer())
+}
+
+// WithFingerprint records the fingerprint of the statements of query spans, which
+// groups the statements that only differ by their literals.
+func WithFingerprint() Option {
+	return func(t *QueryTracer) {
+		t.Fingerprint = true
+	}
+}
+
+// WithStatementStats records the pg_stat_statements statistics cached by stats on
+// query spans. The cache is refreshed by StatementStats.Collect.
+func WithStatementStats(stats *StatementStats) Option {
+	return func(t *QueryTracer) {
+		t.StatementStats = stats
+	}
+}
+
+// WithAttributeMapper sets the function that rewrites the attributes before they
+// are set on a span.
+func WithAttributeMapper(fn func(attrs []attribute.KeyValue) []attribute.KeyValue) Option {
+	return func(t *QueryTracer) {
+		t.AttributeMapper = fn
+	}
+}
+
+// WithSpanNamePrefix prepends the prefix (e.g. "billing-db: ") to the names of the
+// spans.
+func WithSpanNamePrefix(prefix string) Option {
+	return func(t *QueryTracer) {
+		t.SpanNamePrefix = prefix
+	}
+}
+
+// WithVersion sets the instrumentation version of the tracer.
+func WithVersion(version string) Option {
+	return func(t *QueryTracer) {
+		t.Version = version
+	}
+}
+
+// WithSchemaURL sets the schema URL of the tracer.
+func WithSchemaURL(url string) Option {
+	return func(t *QueryTracer) {
+		t.SchemaURL = url
+	}
+}
+
+// WithConnectPhases records the phases of a connection attempt (lookup, dial, TLS
+// handshake and startup) as events of the Connect span. It takes effect in
+// Configure.
+func WithConnectPhases() Option {
+	return func(t *QueryTracer) {
+		t.ConnectPhases = true
+	}
+}
+
+// WithCallerAttributes records the call site of the application on query spans,
+// which tells apart the code paths that issue the same statement.
+func WithCallerAttributes() Option {
+	return func(t *QueryTracer) {
+		t.CallerAttributes = true
+	}
+}
+
+// WithMaxEvents limits the number of events recorded per span.
+func WithMaxEvents(count int) Option {
+	return func(t *QueryTracer) {
+		t.MaxEvents = count
+	}
+}
+
+// WithMaxAttributeBytes truncates the string attribute values and the error
+// messages longer than size bytes, TruncationMarker included.
+func WithMaxAttributeBytes(size int) Option {
+	return func(t *QueryTracer) {
+		t.MaxAttributeBytes = size
+	}
+}
+
+// WithRequestIDFunc sets the function that returns the correlation ID of the
+// request recorded on every span.
+func WithRequestIDFunc(fn func(ctx context.Context) string) Option {
+	return func(t *QueryTracer) {
+		t.RequestIDFunc = fn
+	}
+}
+
+// WithRequestIDKey records the request ID stored in the context under the given
+// key. The value must be a string or a fmt.Stringer.
+func WithRequestIDKey(key any) Option {
+	return WithRequestIDFunc(func(ctx context.Context) string {
+		switch value := ctx.Value(key).(type) {
+		case string:
+			return value
+		case fmt.Stringer:
+			return value.String()
+		default:
+			return ""
+		}
+	})
+}
+
+// WithCompactBatch records the queries of a batch as events of the batch span
+// instead of child spans, which keeps large batches manageable.
+func WithCompactBatch() Option {
+	return func(t *QueryTracer) {
+		t.CompactBatch = true
+	}
+}
+
+// WithBatchRootLink links the spans of the batch queries to the root span of the
+// request carried by the context (see ContextWithRootSpan), in addition to their
+// Batch parent span.
+func WithBatchRootLink() Option {
+	return func(t *QueryTracer) {
+		t.BatchRootLink = true
+	}
+}
+
+// WithOnEnd sets the hook called when a traced operation ends.
+func WithOnEnd(fn func(ctx context.Context, op Operation, err error, span trace.Span)) Option {
+	return func(t *QueryTracer) {
+		t.OnEnd = fn
+	}
+}
+
+// WithOtelpgxCompatibility records the span names and attribute keys of otelpgx,
+// for the teams that migrate from it.
+func WithOtelpgxCompatibility() Option {
+	return func(t *QueryTracer) {
+		t.OtelpgxCompatible = true
+	}
+}
+
+// WithPoolName sets the logical name of the pool recorded on every span and
+// metric.
+func WithPoolName(name string) Option {
+	return func(t *QueryTracer) {
+		t.PoolName = name
+	}
+}
+
+// WithCommentPropagator sets the propagator of the SQL comments appended by
+// Commenter and restricts them to the given keys, if any. The commented
+// statements defeat the statement cache: run them with pgx.QueryExecModeExec
+// (see Commenter).
+func WithCommentPropagator(propagator propagation.TextMapPropagator, keys ...string) Option {
+	return func(t *QueryTracer) {
+		t.CommentPropagator = propagator
+		t.CommentKeys = keys
+	}
+}
+
+// WithClock sets the clock of the tracer, which tests replace to verify the
+// duration-dependent behaviors without sleeping.
+func WithClock(clock Clock) Option {
+	return func(t *QueryTracer) {
+		t.Clock = clock
+	}
+}
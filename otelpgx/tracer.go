@@ -0,0 +1,132 @@
+// Package otelpgx mirrors the constructor and most of the options of otelpgx
+// (github.com/exaring/otelpgx) on top of pgxotel, so that most applications can
+// switch packages by changing the import path. The options of pgxotel can be
+// passed to NewTracer as well.
+//
+// It differs from otelpgx in a few ways:
+//   - WithSpanNameFunc and RecordStats are not provided: the span names can only
+//     be prefixed (see pgxotel.WithSpanNamePrefix), and the statistics of the
+//     pools (see pgxpool.Stat) are not recorded.
+//   - The span names of the statements are always their operation (e.g. SELECT),
+//     so that WithTrimSQLInSpanName and WithDisableQuerySpanNamePrefix have no
+//     effect.
+//   - The metrics are the ones of pgxotel, whose instruments differ from the ones
+//     of otelpgx.
+package otelpgx
+
+import (
+	"slices"
+
+	pgxotel "github.com/pgx-contrib/pgxotel"
+	attribute "go.opentelemetry.io/otel/attribute"
+	metric "go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	stable "go.opentelemetry.io/otel/semconv/v1.26.0"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation name of the tracers, which is the one of
+// otelpgx.
+const TracerName = "github.com/exaring/otelpgx"
+
+// Tracer is a pgxotel.QueryTracer. It implements the tracer interfaces of pgx.
+type Tracer = pgxotel.QueryTracer
+
+// Option configures a Tracer.
+type Option = pgxotel.Option
+
+// NewTracer creates a Tracer that records the span names and the attribute keys
+// of otelpgx, along with the metrics of pgxotel.
+func NewTracer(opts ...Option) *Tracer {
+	options := make([]Option, 0, 2+len(opts))
+	options = append(options, pgxotel.WithOtelpgxCompatibility())
+	options = append(options, pgxotel.WithMetrics())
+	options = append(options, opts...)
+	// done!
+	return pgxotel.NewQueryTracer(TracerName, options...)
+}
+
+// WithTracerProvider sets the provider of the tracer instead of the global one.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return pgxotel.WithTracerProvider(provider)
+}
+
+// WithMeterProvider sets the provider of the meter instead of the global one.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return pgxotel.WithMeterProvider(provider)
+}
+
+// WithAttributes records the attributes on every span.
+func WithAttributes(attrs ...attribute.KeyValue) Option {
+	return pgxotel.WithAttributes(attrs...)
+}
+
+// WithTrimSQLInSpanName is a no-op kept for compatibility: the span names of the
+// statements are always their operation (e.g. SELECT).
+func WithTrimSQLInSpanName() Option {
+	return func(t *Tracer) {}
+}
+
+// WithDisableQuerySpanNamePrefix is a no-op kept for compatibility: the span names
+// of the statements are never prefixed.
+func WithDisableQuerySpanNamePrefix() Option {
+	return func(t *Tracer) {}
+}
+
+// WithDisableSQLStatementInAttributes omits the statements from the attributes.
+func WithDisableSQLStatementInAttributes() Option {
+	return without(semconv.DBStatementKey, stable.DBQueryTextKey)
+}
+
+// WithDisableConnectionDetailsInAttributes omits the addresses, the ports and the
+// user of the server, as well as the connection string, from the attributes of
+// the spans and their events.
+func WithDisableConnectionDetailsInAttributes() Option {
+	drop := without(
+		semconv.DBUserKey,
+		semconv.NetSockPeerAddrKey,
+		semconv.NetSockPeerPortKey,
+		stable.ServerAddressKey,
+		stable.ServerPortKey,
+		stable.NetworkPeerAddressKey,
+		stable.NetworkPeerPortKey,
+		pgxotel.HostKey,
+		pgxotel.HostsKey,
+		pgxotel.AddressKey,
+		attribute.Key("user.name"),
+	)
+
+	return func(t *Tracer) {
+		t.OmitConnectionString = true
+		drop(t)
+	}
+}
+
+// WithIncludeQueryParameters records the values of the bind parameters. Unlike in
+// otelpgx, it only takes effect in binaries built with the pgxotel_debug build
+// tag (see pgxotel.WithParameterValues).
+func WithIncludeQueryParameters() Option {
+	return pgxotel.WithParameterValues()
+}
+
+// without drops the attributes of the keys, before the AttributeMapper of the
+// tracer if any.
+func without(keys ...attribute.Key) Option {
+	return func(t *Tracer) {
+		mapper := t.AttributeMapper
+		t.AttributeMapper = func(attrs []attribute.KeyValue) []attribute.KeyValue {
+			valid := attrs[:0]
+			for _, attr := range attrs {
+				if !slices.Contains(keys, attr.Key) {
+					valid = append(valid, attr)
+				}
+			}
+
+			if mapper != nil {
+				return mapper(valid)
+			}
+
+			return valid
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package pgxotel
+
+import (
+	"context"
+
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	attribute "go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+// ExecParams executes the query on the connection like pgconn.PgConn.ExecParams.
+// The query is traced by a span that ends when the returned reader is read or
+// closed.
+func (t *QueryTracer) ExecParams(ctx context.Context, conn *pgconn.PgConn, sql string, paramValues [][]byte, paramOIDs []uint32, paramFormats []int16, resultFormats []int16) *ResultReader {
+	ctx, span := t.startPgConn(ctx, conn, sql, "", len(paramValues))
+	// execute the query
+	reader := conn.ExecParams(ctx, sql, paramValues, paramOIDs, paramFormats, resultFormats)
+	return &ResultReader{ResultReader: reader, ctx: ctx, tracer: t, conn: conn, span: span}
+}
+
+// ExecPrepared executes the prepared statement on the connection like
+// pgconn.PgConn.ExecPrepared. The statement is traced by an Execute span that ends
+// when the returned reader is read or closed, with the name of the statement.
+func (t *QueryTracer) ExecPrepared(ctx context.Context, conn *pgconn.PgConn, name string, paramValues [][]byte, paramFormats []int16, resultFormats []int16) *ResultReader {
+	ctx, span := t.startPgConn(ctx, conn, "", name, len(paramValues))
+	// execute the statement
+	reader := conn.ExecPrepared(ctx, name, paramValues, paramFormats, resultFormats)
+	return &ResultReader{ResultReader: reader, ctx: ctx, tracer: t, conn: conn, span: span}
+}
+
+// Exec executes the queries on the connection like pgconn.PgConn.Exec. The queries
+// are traced by a span that ends when the returned reader is read or closed.
+func (t *QueryTracer) Exec(ctx context.Context, conn *pgconn.PgConn, sql string) *MultiResultReader {
+	ctx, span := t.startPgConn(ctx, conn, sql, "", 0)
+	// execute the queries
+	reader := conn.Exec(ctx, sql)
+	return &MultiResultReader{MultiResultReader: reader, ctx: ctx, tracer: t, conn: conn, span: span}
+}
+
+// startPgConn starts the span of the query, or of the prepared statement when sql
+// is empty. The database of a pgconn.PgConn is unknown.
+func (t *QueryTracer) startPgConn(ctx context.Context, conn *pgconn.PgConn, sql, prepared string, count int) (context.Context, trace.Span) {
+	ctx = t.begin(ctx, OperationQuery, "", sql)
+	if op := operationFrom(ctx); op != nil && sql == "" {
+		op.name = "EXECUTE"
+	}
+	t.recordActive(ctx, 1)
+
+	if t.DisableSpans || !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, nil
+	}
+
+	if t.disabled(OperationQuery) {
+		return untraced(ctx), nil
+	}
+
+	name := sql
+	if sql == "" {
+		name = "Execute"
+	}
+
+	attrs := []attribute.KeyValue{}
+	attrs = append(attrs, semconv.DBSystemPostgreSQL)
+	attrs = append(attrs, t.peer(conn)...)
+	attrs = append(attrs, t.instance(nil)...)
+	if !t.Minimal {
+		attrs = append(attrs, ParameterCountKey.Int(count))
+		if prepared != "" {
+			attrs = append(attrs, PreparedStatementKey.String(prepared))
+		}
+	}
+	attrs = append(attrs, t.keyword(name)...)
+	// prepare the span
+	ctx, span := t.start(ctx, t.spanName(OperationQuery, name), attrs, t.SpanStartOptions[OperationQuery]...)
+	t.event(span, "QueryStart")
+	if span.IsRecording() && !t.Minimal && sql != "" {
+		t.annotate(span, t.statement(sql))
+	}
+	conn.CustomData()[spanKey] = span
+	// done!
+	return ctx, span
+}
+
+func (t *QueryTracer) stopPgConn(ctx context.Context, conn *pgconn.PgConn, span trace.Span, tag pgconn.CommandTag, err error) {
+	t.recordActive(ctx, -1)
+	if span == nil {
+		t.finish(ctx, nil, tag, err)
+		return
+	}
+
+	t.event(span, "QueryEnd")
+	delete(conn.CustomData(), spanKey)
+
+	attrs := []attribute.KeyValue{}
+	if tag.Select() {
+		attrs = append(attrs, ReturnedRowsKey.Int64(tag.RowsAffected()))
+	}
+	attrs = append(attrs, t.affected(tag, err)...)
+	// done
+	t.stop(ctx, span, tag, err, attrs)
+}
+
+// ResultReader is a pgconn.ResultReader traced by a span.
+type ResultReader struct {
+	*pgconn.ResultReader
+	ctx    context.Context
+	tracer *QueryTracer
+	conn   *pgconn.PgConn
+	span   trace.Span
+	done   bool
+}
+
+// Read implements pgconn.ResultReader.Read and ends the span.
+func (r *ResultReader) Read() *pgconn.Result {
+	result := r.ResultReader.Read()
+	r.end(result.CommandTag, result.Err)
+	return result
+}
+
+// Close implements pgconn.ResultReader.Close and ends the span.
+func (r *ResultReader) Close() (pgconn.CommandTag, error) {
+	tag, err := r.ResultReader.Close()
+	r.end(tag, err)
+	return tag, err
+}
+
+func (r *ResultReader) end(tag pgconn.CommandTag, err error) {
+	if r.done {
+		return
+	}
+
+	r.done = true
+	r.tracer.stopPgConn(r.ctx, r.conn, r.span, tag, err)
+}
+
+// MultiResultReader is a pgconn.MultiResultReader traced by a span.
+type MultiResultReader struct {
+	*pgconn.MultiResultReader
+	ctx    context.Context
+	tracer *QueryTracer
+	conn   *pgconn.PgConn
+	span   trace.Span
+	done   bool
+}
+
+// ReadAll implements pgconn.MultiResultReader.ReadAll and ends the span.
+func (r *MultiResultReader) ReadAll() ([]*pgconn.Result, error) {
+	results, err := r.MultiResultReader.ReadAll()
+
+	var tag pgconn.CommandTag
+	if len(results) > 0 {
+		tag = results[len(results)-1].CommandTag
+	}
+
+	r.end(tag, err)
+	return results, err
+}
+
+// Close implements pgconn.MultiResultReader.Close and ends the span.
+func (r *MultiResultReader) Close() error {
+	err := r.MultiResultReader.Close()
+	r.end(pgconn.CommandTag{}, err)
+	return err
+}
+
+func (r *MultiResultReader) end(tag pgconn.CommandTag, err error) {
+	if r.done {
+		return
+	}
+
+	r.done = true
+	r.tracer.stopPgConn(r.ctx, r.conn, r.span, tag, err)
+}
@@ -0,0 +1,94 @@
+// Package pgxotelmetric provides OpenTelemetry SDK configuration for the metric
+// instruments of pgxotel.
+package pgxotelmetric
+
+import (
+	attribute "go.opentelemetry.io/otel/attribute"
+	metric "go.opentelemetry.io/otel/sdk/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+var (
+	// DurationBoundaries are the bucket boundaries in seconds of the duration
+	// histograms, from a tenth of a millisecond to thirty seconds.
+	DurationBoundaries = []float64{
+		0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05,
+		0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30,
+	}
+	// RowBoundaries are the bucket boundaries of the row count histograms.
+	RowBoundaries = []float64{
+		0, 1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 5000, 10000, 100000,
+	}
+	// LifetimeBoundaries are the bucket boundaries in seconds of the connection
+	// lifetime histogram, from a second to a day.
+	LifetimeBoundaries = []float64{
+		1, 5, 10, 30, 60, 300, 600, 1800, 3600, 7200, 14400, 43200, 86400,
+	}
+)
+
+// keys are the attribute keys recorded by the instruments. The other keys are
+// dropped to keep the cardinality low.
+var keys = []attribute.Key{
+	semconv.DBSystemKey,
+	semconv.DBNameKey,
+	semconv.DBOperationKey,
+	semconv.DBSQLTableKey,
+	attribute.Key("db.namespace"),
+	attribute.Key("db.operation.name"),
+	attribute.Key("db.collection.name"),
+	attribute.Key("db.postgresql.sqlstate_class"),
+	attribute.Key("tenant.id"),
+	attribute.Key("db.client.connections.pool.name"),
+	attribute.Key("pgxotel.limit"),
+	attribute.Key("pgx.statement_cache"),
+	attribute.Key("error.type"),
+	attribute.Key("db.postgresql.connection.close_reason"),
+}
+
+// Views returns the recommended views of the pgxotel instruments: explicit bucket
+// boundaries that fit database latencies and row counts, and attribute filters
+// that keep the cardinality low.
+func Views() []metric.View {
+	filter := attribute.NewAllowKeysFilter(keys...)
+
+	histogram := func(name string, boundaries []float64) metric.View {
+		return metric.NewView(
+			metric.Instrument{Name: name},
+			metric.Stream{
+				Aggregation:     metric.AggregationExplicitBucketHistogram{Boundaries: boundaries},
+				AttributeFilter: filter,
+			},
+		)
+	}
+
+	counter := func(name string) metric.View {
+		return metric.NewView(
+			metric.Instrument{Name: name},
+			metric.Stream{AttributeFilter: filter},
+		)
+	}
+
+	return []metric.View{
+		histogram("db.client.response.returned_rows", RowBoundaries),
+		histogram("db.client.connection.wait_time", DurationBoundaries),
+		histogram("db.client.copy.duration", DurationBoundaries),
+		counter("db.client.copy.rows"),
+		counter("db.client.operation.errors"),
+		counter("db.client.operations.active"),
+		counter("db.client.deadlocks"),
+		counter("db.client.serialization_failures"),
+		counter("db.client.telemetry.limited"),
+		counter("db.client.statement_cache.hits"),
+		counter("db.client.statement_cache.misses"),
+		counter("db.client.statement_cache.evictions"),
+		counter("db.client.statement_cache.invalidations"),
+		counter("db.client.statement_cache.size"),
+		counter("db.client.connection.attempts"),
+		counter("db.client.connection.successes"),
+		counter("db.client.connection.failures"),
+		histogram("db.client.connection.create_time", DurationBoundaries),
+		histogram("db.client.connection.lifetime", LifetimeBoundaries),
+		counter("db.client.health.up"),
+		counter("db.client.health.last_success"),
+	}
+}
@@ -0,0 +1,15 @@
+package pgxotelmetric_test
+
+import (
+	"github.com/pgx-contrib/pgxotel/pgxotelmetric"
+	"go.opentelemetry.io/otel"
+	metric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+func ExampleViews() {
+	provider := metric.NewMeterProvider(
+		metric.WithView(pgxotelmetric.Views()...),
+	)
+	// the tracer uses the global provider
+	otel.SetMeterProvider(provider)
+}
@@ -0,0 +1,147 @@
+package pgxoteltest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	attribute "go.opentelemetry.io/otel/attribute"
+)
+
+// Matcher matches a recorded span.
+type Matcher struct {
+	description string
+	match       func(Span) bool
+}
+
+// String returns the description of the matcher.
+func (m Matcher) String() string {
+	return m.description
+}
+
+// Match reports whether the span matches.
+func (m Matcher) Match(span Span) bool {
+	return m.match(span)
+}
+
+// WithName matches the spans with the given name.
+func WithName(name string) Matcher {
+	return Matcher{
+		description: fmt.Sprintf("name %q", name),
+		match:       func(span Span) bool { return span.Name == name },
+	}
+}
+
+// WithOperation matches the spans of the given operation (e.g. SELECT).
+func WithOperation(operation string) Matcher {
+	return Matcher{
+		description: fmt.Sprintf("operation %q", operation),
+		match:       func(span Span) bool { return strings.EqualFold(span.Operation, operation) },
+	}
+}
+
+// WithStatement matches the spans with the given statement.
+func WithStatement(statement string) Matcher {
+	return Matcher{
+		description: fmt.Sprintf("statement %q", statement),
+		match:       func(span Span) bool { return span.Statement == statement },
+	}
+}
+
+// WithTable matches the spans of the given table.
+func WithTable(table string) Matcher {
+	return Matcher{
+		description: fmt.Sprintf("table %q", table),
+		match:       func(span Span) bool { return span.Table == table },
+	}
+}
+
+// WithError matches the spans that recorded the given error. A nil error matches
+// the spans without an error.
+func WithError(err error) Matcher {
+	if err == nil {
+		return Matcher{
+			description: "no error",
+			match:       func(span Span) bool { return span.Error == "" },
+		}
+	}
+
+	return Matcher{
+		description: fmt.Sprintf("error %q", err.Error()),
+		match:       func(span Span) bool { return span.Error == err.Error() },
+	}
+}
+
+// WithAttribute matches the spans with the given attribute.
+func WithAttribute(attr attribute.KeyValue) Matcher {
+	return Matcher{
+		description: fmt.Sprintf("attribute %s=%s", attr.Key, attr.Value.Emit()),
+		match: func(span Span) bool {
+			value, ok := span.Attribute(attr.Key)
+			return ok && value == attr.Value
+		},
+	}
+}
+
+// QuerySpans returns the spans that have a statement and match all matchers.
+func QuerySpans(spans []Span, matchers ...Matcher) []Span {
+	matched := []Span{}
+
+	for _, span := range spans {
+		if span.Statement == "" {
+			continue
+		}
+
+		if match(span, matchers) {
+			matched = append(matched, span)
+		}
+	}
+
+	return matched
+}
+
+// AssertQuerySpan asserts that exactly one query span matches all matchers and
+// returns it.
+func AssertQuerySpan(t testing.TB, spans []Span, matchers ...Matcher) Span {
+	t.Helper()
+
+	matched := QuerySpans(spans, matchers...)
+	if len(matched) != 1 {
+		t.Errorf("expected 1 query span with %s, got %d of %d spans", describe(matchers), len(matched), len(spans))
+		return Span{}
+	}
+
+	return matched[0]
+}
+
+// AssertQueryCount asserts that exactly count query spans match all matchers.
+func AssertQueryCount(t testing.TB, spans []Span, count int, matchers ...Matcher) {
+	t.Helper()
+
+	if matched := QuerySpans(spans, matchers...); len(matched) != count {
+		t.Errorf("expected %d query spans with %s, got %d of %d spans", count, describe(matchers), len(matched), len(spans))
+	}
+}
+
+func match(span Span, matchers []Matcher) bool {
+	for _, matcher := range matchers {
+		if !matcher.Match(span) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func describe(matchers []Matcher) string {
+	if len(matchers) == 0 {
+		return "any attributes"
+	}
+
+	descriptions := make([]string, 0, len(matchers))
+	for _, matcher := range matchers {
+		descriptions = append(descriptions, matcher.String())
+	}
+
+	return strings.Join(descriptions, ", ")
+}
@@ -0,0 +1,98 @@
+package pgxoteltest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/pgx-contrib/pgxotel"
+	"github.com/pgx-contrib/pgxotel/pgxoteltest"
+)
+
+// record records a query with a tracer configured with the options.
+func record(recorder *pgxoteltest.Recorder, sql string, err error, opts ...pgxotel.Option) []pgxoteltest.Span {
+	tracer := pgxotel.NewQueryTracer("example-api", append(opts, recorder.Option())...)
+
+	ctx, span := recorder.Start(context.TODO(), "test")
+	tracer.Record(ctx, pgxotel.Operation{Type: pgxotel.OperationQuery, SQL: sql}, err)
+	span.End()
+
+	return recorder.Spans()
+}
+
+func TestQuerySpans(t *testing.T) {
+	recorder := pgxoteltest.NewRecorder()
+	// the otelpgx keys are the stable ones
+	spans := record(recorder, "SELECT name FROM customer", nil, pgxotel.WithOtelpgxCompatibility())
+
+	span := pgxoteltest.AssertQuerySpan(t, spans,
+		pgxoteltest.WithStatement("SELECT name FROM customer"),
+		pgxoteltest.WithOperation("SELECT"),
+		pgxoteltest.WithTable("customer"),
+	)
+
+	if span.Name != "SELECT" {
+		t.Errorf("expected the span SELECT, got %q", span.Name)
+	}
+}
+
+// failures records the failures of the assertions.
+type failures struct {
+	testing.TB
+	messages []string
+}
+
+func (f *failures) Helper() {}
+
+func (f *failures) Errorf(format string, args ...any) {
+	f.messages = append(f.messages, fmt.Sprintf(format, args...))
+}
+
+func TestAssertQuerySpan(t *testing.T) {
+	recorder := pgxoteltest.NewRecorder()
+	// a failed update
+	record(recorder, "UPDATE customer SET name = $1", errors.New("deadlock"))
+	// a successful select
+	spans := record(recorder, "SELECT name FROM customer", nil)
+
+	span := pgxoteltest.AssertQuerySpan(t, spans, pgxoteltest.WithOperation("UPDATE"), pgxoteltest.WithError(errors.New("deadlock")))
+	if span.Statement != "UPDATE customer SET name = $1" {
+		t.Errorf("expected the UPDATE span, got %q", span.Statement)
+	}
+
+	pgxoteltest.AssertQueryCount(t, spans, 1, pgxoteltest.WithError(nil))
+	pgxoteltest.AssertQueryCount(t, spans, 2, pgxoteltest.WithTable("customer"))
+
+	f := &failures{}
+	// no span matches
+	pgxoteltest.AssertQuerySpan(f, spans, pgxoteltest.WithOperation("DELETE"))
+	// two spans match
+	pgxoteltest.AssertQuerySpan(f, spans)
+	pgxoteltest.AssertQueryCount(f, spans, 3)
+
+	expected := []string{
+		`expected 1 query span with operation "DELETE", got 0 of 2 spans`,
+		`expected 1 query span with any attributes, got 2 of 2 spans`,
+		`expected 3 query spans with any attributes, got 2 of 2 spans`,
+	}
+
+	if !slices.Equal(f.messages, expected) {
+		t.Errorf("expected the failures %q, got %q", expected, f.messages)
+	}
+}
+
+func TestQuerySpans_statement(t *testing.T) {
+	recorder := pgxoteltest.NewRecorder()
+	tracer := pgxotel.NewQueryTracer("example-api", recorder.Option())
+
+	ctx, span := recorder.Start(context.TODO(), "test")
+	// the connect span has no statement
+	tracer.Record(ctx, pgxotel.Operation{Type: pgxotel.OperationConnect}, nil)
+	span.End()
+
+	if spans := recorder.Spans(); len(spans) != 1 || len(pgxoteltest.QuerySpans(spans)) != 0 {
+		t.Errorf("expected only the connect span, got %d query spans", len(pgxoteltest.QuerySpans(spans)))
+	}
+}
@@ -0,0 +1,174 @@
+package pgxoteltest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pgx-contrib/pgxotel"
+)
+
+var _ pgxotel.Clock = (*Clock)(nil)
+
+// Clock is a pgxotel.Clock whose time only moves when it is advanced, so that the
+// duration-dependent behaviors can be tested without sleeping.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*timer
+	calls  sync.WaitGroup
+}
+
+// NewClock creates a Clock set to the given time.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Option configures a tracer to use the clock.
+func (c *Clock) Option() pgxotel.Option {
+	return pgxotel.WithClock(c)
+}
+
+// Now implements pgxotel.Clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// AfterFunc implements pgxotel.Clock. The function is called in its own goroutine
+// by Advance once the duration elapsed.
+func (c *Clock) AfterFunc(d time.Duration, fn func()) pgxotel.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &timer{clock: c, when: c.now.Add(d), fn: fn}
+	c.timers = append(c.timers, t)
+	// done!
+	return t
+}
+
+// NewTicker implements pgxotel.Clock. The ticks are sent by Advance and dropped
+// when the previous tick was not received, like the ones of time.Ticker.
+func (c *Clock) NewTicker(period time.Duration) pgxotel.Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &timer{clock: c, when: c.now.Add(period), period: period, ch: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	// done!
+	return ticker{timer: t}
+}
+
+// Advance moves the clock forward by the duration and fires the timers and the
+// tickers that are due. It returns once the functions of the fired timers have
+// returned.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		t, ok := c.next(end)
+		if !ok {
+			break
+		}
+
+		t.fire()
+	}
+
+	c.mu.Lock()
+	c.now = end
+	c.mu.Unlock()
+	// wait for the functions
+	c.calls.Wait()
+}
+
+// next returns the earliest timer that is due by end and moves the clock to it.
+func (c *Clock) next(end time.Time) (*timer, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var next *timer
+	for _, t := range c.timers {
+		if t.when.After(end) {
+			continue
+		}
+
+		if next == nil || t.when.Before(next.when) {
+			next = t
+		}
+	}
+
+	if next == nil {
+		return nil, false
+	}
+
+	c.now = next.when
+	if next.period > 0 {
+		next.when = next.when.Add(next.period)
+	} else {
+		c.remove(next)
+	}
+
+	return next, true
+}
+
+func (c *Clock) remove(t *timer) bool {
+	for index, value := range c.timers {
+		if value == t {
+			c.timers = append(c.timers[:index], c.timers[index+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// timer is a timer or a ticker of a Clock.
+type timer struct {
+	clock  *Clock
+	when   time.Time
+	period time.Duration
+	fn     func()
+	ch     chan time.Time
+}
+
+func (t *timer) fire() {
+	if t.fn != nil {
+		t.clock.calls.Add(1)
+		go func() {
+			defer t.clock.calls.Done()
+			t.fn()
+		}()
+		return
+	}
+
+	select {
+	case t.ch <- t.clock.Now():
+	default:
+	}
+}
+
+// Stop implements pgxotel.Timer.
+func (t *timer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	return t.clock.remove(t)
+}
+
+// ticker is a ticker of a Clock.
+type ticker struct {
+	timer *timer
+}
+
+// C implements pgxotel.Ticker.
+func (t ticker) C() <-chan time.Time {
+	return t.timer.ch
+}
+
+// Stop implements pgxotel.Ticker.
+func (t ticker) Stop() {
+	t.timer.Stop()
+}
@@ -0,0 +1,164 @@
+// Package pgxoteltest records the spans of pgxotel in memory for tests.
+package pgxoteltest
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pgx-contrib/pgxotel"
+	attribute "go.opentelemetry.io/otel/attribute"
+	codes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	tracetest "go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	stable "go.opentelemetry.io/otel/semconv/v1.26.0"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+// Recorder records the spans created by the tracers configured with its Option.
+type Recorder struct {
+	recorder *tracetest.SpanRecorder
+	provider *sdktrace.TracerProvider
+}
+
+// NewRecorder creates a Recorder that samples every span.
+func NewRecorder() *Recorder {
+	recorder := tracetest.NewSpanRecorder()
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSpanProcessor(recorder),
+	)
+
+	return &Recorder{recorder: recorder, provider: provider}
+}
+
+// TracerProvider returns the provider of the recorded spans.
+func (r *Recorder) TracerProvider() trace.TracerProvider {
+	return r.provider
+}
+
+// Option configures a tracer to record its spans.
+func (r *Recorder) Option() pgxotel.Option {
+	return pgxotel.WithTracerProvider(r.provider)
+}
+
+// Start starts a parent span for the operations under test. The tracer only
+// creates spans within a recording span.
+func (r *Recorder) Start(ctx context.Context, name string) (context.Context, trace.Span) {
+	return r.provider.Tracer("pgxoteltest").Start(ctx, name)
+}
+
+// Spans returns the ended spans, except the ones created by Start.
+func (r *Recorder) Spans() []Span {
+	spans := []Span{}
+
+	for _, span := range r.recorder.Ended() {
+		if span.InstrumentationScope().Name == "pgxoteltest" {
+			continue
+		}
+
+		spans = append(spans, newSpan(span))
+	}
+
+	return spans
+}
+
+// Reset forgets the recorded spans.
+func (r *Recorder) Reset() {
+	r.recorder.Reset()
+}
+
+// Shutdown shuts the provider down.
+func (r *Recorder) Shutdown(ctx context.Context) error {
+	return r.provider.Shutdown(ctx)
+}
+
+// Span is a recorded span.
+type Span struct {
+	// Name is the name of the span
+	Name string
+	// Statement is the db.statement (or db.query.text) attribute
+	Statement string
+	// Operation is the name of the operation (e.g. SELECT)
+	Operation string
+	// Table is the db.sql.table (or db.collection.name) attribute
+	Table string
+	// Error is the message of the recorded error, if any
+	Error string
+	// Status is the status code of the span
+	Status codes.Code
+	// Duration is the duration of the span
+	Duration time.Duration
+	// Attributes are the attributes of the span
+	Attributes []attribute.KeyValue
+	// Events are the names of the events of the span
+	Events []string
+	// SpanContext is the span context of the span
+	SpanContext trace.SpanContext
+	// Parent is the span context of the parent span
+	Parent trace.SpanContext
+}
+
+// Attribute returns the value of the attribute with the given key.
+func (s Span) Attribute(key attribute.Key) (attribute.Value, bool) {
+	for _, attr := range s.Attributes {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+
+	return attribute.Value{}, false
+}
+
+// lookup returns the value of the first attribute with one of the keys, so that
+// the stable semantic conventions and the otelpgx keys are recognized as well.
+func (s Span) lookup(keys ...attribute.Key) (attribute.Value, bool) {
+	for _, key := range keys {
+		if value, ok := s.Attribute(key); ok {
+			return value, true
+		}
+	}
+
+	return attribute.Value{}, false
+}
+
+func newSpan(span sdktrace.ReadOnlySpan) Span {
+	s := Span{
+		Name:        span.Name(),
+		Status:      span.Status().Code,
+		Duration:    span.EndTime().Sub(span.StartTime()),
+		Attributes:  span.Attributes(),
+		SpanContext: span.SpanContext(),
+		Parent:      span.Parent(),
+	}
+
+	if value, ok := s.lookup(semconv.DBStatementKey, stable.DBQueryTextKey); ok {
+		s.Statement = value.AsString()
+	}
+
+	if value, ok := s.lookup(semconv.DBSQLTableKey, stable.DBCollectionNameKey); ok {
+		s.Table = value.AsString()
+	}
+
+	if value, ok := s.lookup(semconv.DBOperationKey, stable.DBOperationNameKey); ok {
+		s.Operation = value.AsString()
+	} else if fields := strings.Fields(s.Statement); len(fields) > 0 {
+		s.Operation = strings.ToUpper(fields[0])
+	}
+
+	for _, event := range span.Events() {
+		s.Events = append(s.Events, event.Name)
+		// the error is recorded as an exception event
+		if event.Name == semconv.ExceptionEventName {
+			for _, attr := range event.Attributes {
+				if attr.Key == semconv.ExceptionMessageKey {
+					s.Error = attr.Value.AsString()
+				}
+			}
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,99 @@
+package pgxoteltest_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pgx-contrib/pgxotel"
+	"github.com/pgx-contrib/pgxotel/pgxoteltest"
+)
+
+func ExampleRecorder() {
+	recorder := pgxoteltest.NewRecorder()
+
+	config, err := pgx.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	config.Tracer = pgxotel.NewQueryTracer("example-api", recorder.Option())
+
+	ctx, span := recorder.Start(context.TODO(), "test")
+
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		panic(err)
+	}
+	// close the connection
+	defer conn.Close(context.TODO())
+
+	if _, err := conn.Exec(ctx, "SELECT 1"); err != nil {
+		panic(err)
+	}
+
+	span.End()
+
+	for _, span := range recorder.Spans() {
+		fmt.Println(span.Name, span.Operation, span.Statement, span.Error)
+	}
+}
+
+func ExampleAssertQueryCount() {
+	recorder := pgxoteltest.NewRecorder()
+
+	config, err := pgx.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	config.Tracer = pgxotel.NewQueryTracer("example-api", recorder.Option())
+
+	ctx, span := recorder.Start(context.TODO(), "test")
+
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		panic(err)
+	}
+	// close the connection
+	defer conn.Close(context.TODO())
+
+	if _, err := conn.Exec(ctx, "SELECT 1"); err != nil {
+		panic(err)
+	}
+
+	span.End()
+
+	// the connection performed exactly one query without an error
+	pgxoteltest.AssertQueryCount(reporter{}, recorder.Spans(), 1, pgxoteltest.WithOperation("SELECT"), pgxoteltest.WithError(nil))
+}
+
+// reporter fails the examples when an assertion fails.
+type reporter struct {
+	testing.TB
+}
+
+func (reporter) Helper() {}
+
+func (reporter) Errorf(format string, args ...any) {
+	panic(fmt.Sprintf(format, args...))
+}
+
+func ExampleClock() {
+	clock := pgxoteltest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	ticker := clock.NewTicker(time.Minute)
+	// stop the ticker
+	defer ticker.Stop()
+
+	clock.Advance(90 * time.Second)
+
+	fmt.Println(<-ticker.C())
+	fmt.Println(clock.Now())
+	// Output:
+	// 2024-01-01 00:01:00 +0000 UTC
+	// 2024-01-01 00:01:30 +0000 UTC
+}
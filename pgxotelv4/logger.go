@@ -0,0 +1,116 @@
+// Package pgxotelv4 records the operations of pgx v4, which lacks the tracer
+// interfaces, as pgxotel spans.
+package pgxotelv4
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pgconnv1 "github.com/jackc/pgconn"
+	pgx "github.com/jackc/pgx/v4"
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	pgxotel "github.com/pgx-contrib/pgxotel"
+)
+
+// Logger is a pgx v4 logger that records the logged operations with the Tracer.
+// The operations are only logged when the log level of the connection is at
+// least pgx.LogLevelInfo.
+type Logger struct {
+	// Tracer records the operations
+	Tracer *pgxotel.QueryTracer
+	// Database is the name of the database of the connections
+	Database string
+	// Logger receives the log entries as well, if set
+	Logger pgx.Logger
+}
+
+// NewLogger creates a Logger that records the operations on the database with the
+// tracer.
+func NewLogger(tracer *pgxotel.QueryTracer, config *pgx.ConnConfig) *Logger {
+	return &Logger{
+		Tracer:   tracer,
+		Database: config.Database,
+		Logger:   config.Logger,
+	}
+}
+
+// Log implements pgx.Logger.
+func (l *Logger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	if l.Logger != nil {
+		l.Logger.Log(ctx, level, msg, data)
+	}
+
+	op, ok := l.operation(msg, data)
+	if !ok {
+		return
+	}
+
+	err, _ := data["err"].(error)
+	// done!
+	l.Tracer.Record(ctx, op, err)
+}
+
+// Prepare prepares the statement on the connection and records the successful
+// preparations, which pgx v4 does not log. The failed ones are recorded from the
+// "Prepare failed" log entry.
+func (l *Logger) Prepare(ctx context.Context, conn *pgx.Conn, name, sql string) (*pgconnv1.StatementDescription, error) {
+	start := time.Now()
+
+	sd, err := conn.Prepare(ctx, name, sql)
+	if err == nil {
+		l.Tracer.Record(ctx, pgxotel.Operation{
+			Type:     pgxotel.OperationPrepare,
+			SQL:      sql,
+			Database: l.Database,
+			Start:    start,
+			Duration: time.Since(start),
+		}, nil)
+	}
+
+	return sd, err
+}
+
+// operation returns the operation of the log entry, if any.
+func (l *Logger) operation(msg string, data map[string]interface{}) (pgxotel.Operation, bool) {
+	op := pgxotel.Operation{
+		Database: l.Database,
+	}
+
+	op.Duration, _ = data["time"].(time.Duration)
+	op.SQL, _ = data["sql"].(string)
+
+	switch msg {
+	case "Query":
+		op.Type = pgxotel.OperationQuery
+		if count, ok := data["rowCount"].(int); ok {
+			op.CommandTag = pgconn.NewCommandTag(fmt.Sprintf("SELECT %d", count))
+		}
+	case "Exec":
+		op.Type = pgxotel.OperationQuery
+		if tag, ok := data["commandTag"].(fmt.Stringer); ok {
+			op.CommandTag = pgconn.NewCommandTag(tag.String())
+		}
+	case "BatchResult.Exec", "BatchResult.Query":
+		op.Type = pgxotel.OperationBatchQuery
+		if tag, ok := data["commandTag"].(fmt.Stringer); ok {
+			op.CommandTag = pgconn.NewCommandTag(tag.String())
+		}
+	case "SendBatch":
+		op.Type = pgxotel.OperationBatch
+	case "CopyFrom":
+		op.Type = pgxotel.OperationCopyFrom
+		if name, ok := data["tableName"].(pgx.Identifier); ok {
+			op.Table = name.Sanitize()
+		}
+		if count, ok := data["rowCount"].(int64); ok {
+			op.CommandTag = pgconn.NewCommandTag(fmt.Sprintf("COPY %d", count))
+		}
+	case "Prepare failed":
+		op.Type = pgxotel.OperationPrepare
+	default:
+		return op, false
+	}
+
+	return op, true
+}
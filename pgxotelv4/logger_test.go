@@ -0,0 +1,38 @@
+package pgxotelv4_test
+
+import (
+	"context"
+	"os"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pgx-contrib/pgxotel"
+	"github.com/pgx-contrib/pgxotel/pgxotelv4"
+)
+
+func ExampleNewLogger() {
+	config, err := pgx.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	tracer := &pgxotel.QueryTracer{
+		Name: "example-api",
+	}
+
+	config.Logger = pgxotelv4.NewLogger(tracer, config)
+	config.LogLevel = pgx.LogLevelInfo
+
+	conn, err := pgx.ConnectConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the connection
+	defer conn.Close(context.TODO())
+
+	rows, err := conn.Query(context.TODO(), "SELECT * from customer")
+	if err != nil {
+		panic(err)
+	}
+	// close the rows
+	defer rows.Close()
+}
@@ -0,0 +1,39 @@
+package pgxotel
+
+import (
+	"context"
+	"time"
+
+	pgxpool "github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ pgxpool.AcquireTracer = (*QueryTracer)(nil)
+
+type acquireKey struct{}
+
+// TraceAcquireStart implements pgxpool.AcquireTracer.
+func (t *QueryTracer) TraceAcquireStart(ctx context.Context, pool *pgxpool.Pool, data pgxpool.TraceAcquireStartData) context.Context {
+	if !t.Metrics {
+		return ctx
+	}
+
+	return context.WithValue(ctx, acquireKey{}, t.clock().Now())
+}
+
+// TraceAcquireEnd implements pgxpool.AcquireTracer.
+func (t *QueryTracer) TraceAcquireEnd(ctx context.Context, pool *pgxpool.Pool, data pgxpool.TraceAcquireEndData) {
+	start, ok := ctx.Value(acquireKey{}).(time.Time)
+	if !ok {
+		return
+	}
+
+	var database string
+	// the config of the pool is copied on every call
+	if data.Conn != nil {
+		database = t.cache(data.Conn).config.Database
+	} else {
+		database = pool.Config().ConnConfig.Database
+	}
+
+	t.recordAcquire(ctx, database, t.since(start))
+}
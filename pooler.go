@@ -0,0 +1,61 @@
+package pgxotel
+
+import (
+	"strings"
+
+	pgx "github.com/jackc/pgx/v5"
+	attribute "go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// PoolerKey is the attribute key for the connection pooler (e.g. pgbouncer or
+	// pgcat) between the application and the server.
+	PoolerKey = attribute.Key("db.pooler")
+	// PoolModeKey is the attribute key for the pool mode (session, transaction or
+	// statement) of the connection pooler.
+	PoolModeKey = attribute.Key("db.pooler.pool_mode")
+)
+
+// poolerPort is the default port of PgBouncer and pgcat.
+const poolerPort = 6432
+
+// poolers are the poolers detected from the host names.
+var poolers = []string{"pgbouncer", "pgcat", "odyssey", "pgpool"}
+
+// pooler returns the pooler attributes of the connection config.
+func (t *QueryTracer) pooler(config *pgx.ConnConfig) []attribute.KeyValue {
+	name := t.Pooler
+	if name == "" && t.DetectPooler {
+		name = detectPooler(config)
+	}
+
+	if name == "" {
+		return nil
+	}
+
+	attrs := []attribute.KeyValue{}
+	attrs = append(attrs, PoolerKey.String(name))
+	if t.PoolMode != "" {
+		attrs = append(attrs, PoolModeKey.String(t.PoolMode))
+	}
+
+	return attrs
+}
+
+// detectPooler guesses the pooler of the connection config from the host name
+// and the port, on a best-effort basis.
+func detectPooler(config *pgx.ConnConfig) string {
+	host := strings.ToLower(config.Host)
+	for _, name := range poolers {
+		if strings.Contains(host, name) {
+			return name
+		}
+	}
+
+	// PgBouncer is the most common pooler on its default port
+	if config.Port == poolerPort {
+		return "pgbouncer"
+	}
+
+	return ""
+}
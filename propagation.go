@@ -0,0 +1,147 @@
+package pgxotel
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+
+	pgx "github.com/jackc/pgx/v5"
+	otel "go.opentelemetry.io/otel"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+// traceIDKey is the connection custom data key of the trace ID state.
+const traceIDKey = "pgxotel.trace_id"
+
+// traceIDState tracks the trace ID propagated on a connection.
+type traceIDState struct {
+	original string
+	traceID  trace.TraceID
+	updated  time.Time
+}
+
+// AfterConnect discovers the facts about the connection the tracer records and
+// propagates the trace ID of ctx when a connection is established. The failures
+// are handled by otel.Handle and never reject the connection. It can be used as
+// pgxpool.Config.AfterConnect.
+func (t *QueryTracer) AfterConnect(ctx context.Context, conn *pgx.Conn) error {
+	t.discover(ctx, conn)
+
+	if err := t.PropagateTraceID(ctx, conn); err != nil {
+		otel.Handle(err)
+	}
+
+	return nil
+}
+
+// BeforeAcquire propagates the trace ID of ctx when a connection is acquired, or
+// restores the original value of the trace ID parameter when ctx is not traced.
+// The connection keeps the trace ID of the previous checkout until then, so that
+// the checkouts of the same trace do not update it again. A failed update only
+// rejects the connection when it closed it. It can be used as
+// pgxpool.Config.BeforeAcquire.
+func (t *QueryTracer) BeforeAcquire(ctx context.Context, conn *pgx.Conn) bool {
+	err := t.PropagateTraceID(ctx, conn)
+	if err == nil && !trace.SpanContextFromContext(ctx).IsValid() {
+		err = t.RestoreTraceID(ctx, conn)
+	}
+
+	if err != nil {
+		otel.Handle(err)
+	}
+	// pgconn closes the connection when the update is interrupted
+	return !conn.IsClosed()
+}
+
+// PropagateTraceID sets the TraceIDParameter run-time parameter of the connection
+// to include the trace ID of ctx. The update is skipped when the connection
+// already carries the trace ID or was updated within TraceIDInterval, in which
+// case the connection keeps the trace ID of a previous trace.
+func (t *QueryTracer) PropagateTraceID(ctx context.Context, conn *pgx.Conn) error {
+	if t.TraceIDParameter == "" {
+		return nil
+	}
+
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+
+	state := t.traceIDState(conn)
+	// throttle the round trips
+	if state.traceID == sc.TraceID() {
+		return nil
+	}
+
+	if t.TraceIDInterval > 0 && t.since(state.updated) < t.TraceIDInterval {
+		return nil
+	}
+
+	value := sc.TraceID().String()
+	// application_name keeps the original name as a prefix
+	if t.TraceIDParameter == "application_name" && state.original != "" {
+		// the server truncates the name to 63 bytes
+		prefix := state.original
+		if size := 63 - len(value) - 1; len(prefix) > size {
+			// the prefix is cut at a rune boundary
+			for size > 0 && !utf8.RuneStart(prefix[size]) {
+				size--
+			}
+
+			prefix = prefix[:size]
+		}
+
+		value = prefix + " " + value
+	}
+
+	if err := t.setParameter(ctx, conn, value); err != nil {
+		return err
+	}
+
+	state.traceID = sc.TraceID()
+	state.updated = t.clock().Now()
+	// done!
+	return nil
+}
+
+// RestoreTraceID restores the original value of the TraceIDParameter run-time
+// parameter of the connection.
+func (t *QueryTracer) RestoreTraceID(ctx context.Context, conn *pgx.Conn) error {
+	if t.TraceIDParameter == "" {
+		return nil
+	}
+
+	state := t.traceIDState(conn)
+	if !state.traceID.IsValid() {
+		return nil
+	}
+
+	if err := t.setParameter(ctx, conn, state.original); err != nil {
+		return err
+	}
+
+	state.traceID = trace.TraceID{}
+	state.updated = time.Time{}
+	// done!
+	return nil
+}
+
+func (t *QueryTracer) traceIDState(conn *pgx.Conn) *traceIDState {
+	data := conn.PgConn().CustomData()
+
+	state, ok := data[traceIDKey].(*traceIDState)
+	if !ok {
+		state = &traceIDState{
+			original: conn.PgConn().ParameterStatus(t.TraceIDParameter),
+		}
+		data[traceIDKey] = state
+	}
+
+	return state
+}
+
+func (t *QueryTracer) setParameter(ctx context.Context, conn *pgx.Conn, value string) error {
+	// set the parameter for the session
+	_, err := conn.Exec(untraced(ctx), "SELECT set_config($1, $2, false)", t.TraceIDParameter, value)
+	return err
+}
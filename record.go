@@ -0,0 +1,90 @@
+package pgxotel
+
+import (
+	"context"
+
+	attribute "go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+// spanNames are the span names of the operations that do not have a statement.
+var spanNames = map[OperationType]string{
+	OperationConnect:  "Connect",
+	OperationPrepare:  "Prepare",
+	OperationBatch:    "BatchStart",
+	OperationCopyFrom: "Copy",
+}
+
+// Record records an operation that already ended, for the drivers that report
+// their operations after the fact (see the pgxotelv4 package). The span starts at
+// op.Start, or op.Duration ago when the start is unknown. The error metrics and
+// OnEnd are recorded like for the traced operations.
+func (t *QueryTracer) Record(ctx context.Context, op Operation, err error) {
+	if op.Start.IsZero() {
+		op.Start = t.clock().Now().Add(-op.Duration)
+	}
+
+	if op.Name == "" {
+		if op.Name = names[op.Type]; op.Name == "" {
+			op.Name = OperationFromSQL(op.SQL)
+		}
+	}
+
+	if t.Metrics || t.OnEnd != nil {
+		ctx = context.WithValue(ctx, operationKey{}, &operation{
+			kind:     op.Type,
+			database: op.Database,
+			name:     op.Name,
+			sql:      op.SQL,
+			table:    op.Table,
+			start:    op.Start,
+		})
+	}
+
+	if t.DisableSpans || !trace.SpanFromContext(ctx).IsRecording() || t.disabled(op.Type) {
+		t.finish(ctx, nil, op.CommandTag, err)
+		return
+	}
+
+	name := op.SQL
+	if name == "" {
+		name = spanNames[op.Type]
+	}
+
+	buffer := newBuffer()
+	attrs := buffer.attrs
+	attrs = append(attrs, semconv.DBSystemPostgreSQL)
+	if op.Database != "" {
+		attrs = append(attrs, semconv.DBName(op.Database))
+	}
+	if op.Table != "" {
+		attrs = append(attrs, semconv.DBSQLTable(op.Table))
+	} else {
+		attrs = append(attrs, t.table(op.SQL)...)
+	}
+	attrs = append(attrs, t.fingerprint(op.SQL)...)
+	attrs = append(attrs, t.keyword(op.SQL)...)
+
+	options := make([]trace.SpanStartOption, 0, 1+len(t.SpanStartOptions[op.Type]))
+	options = append(options, trace.WithTimestamp(op.Start))
+	options = append(options, t.SpanStartOptions[op.Type]...)
+	// prepare the span
+	ctx, span := t.start(ctx, t.spanName(op.Type, name), attrs, options...)
+	buffer.free(attrs)
+	// the statement is only sanitized for sampled spans
+	if span.IsRecording() && !t.Minimal && op.SQL != "" {
+		t.annotate(span, t.statement(op.SQL))
+	}
+
+	attrs = []attribute.KeyValue{}
+	if op.CommandTag.String() != "" {
+		attrs = append(attrs, t.command(op.CommandTag))
+	}
+	if op.CommandTag.Select() {
+		attrs = append(attrs, ReturnedRowsKey.Int64(op.CommandTag.RowsAffected()))
+	}
+	attrs = append(attrs, t.affected(op.CommandTag, err)...)
+	// done
+	t.stop(ctx, span, op.CommandTag, err, attrs)
+}
@@ -0,0 +1,193 @@
+package pgxotel
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	pgxpool "github.com/jackc/pgx/v5/pgxpool"
+	otel "go.opentelemetry.io/otel"
+	attribute "go.opentelemetry.io/otel/attribute"
+	metric "go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+// ReplicaKey is the attribute key for the application name of a replica
+// reported by pg_stat_replication.
+const ReplicaKey = attribute.Key("db.postgresql.replica")
+
+const (
+	// standbyLagQuery reads the lag of a standby.
+	standbyLagQuery = `SELECT
+COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)::float8,
+COALESCE(pg_wal_lsn_diff(pg_last_wal_receive_lsn(), pg_last_wal_replay_lsn()), 0)::int8`
+	// primaryLagQuery reads the lag of the replicas of a primary.
+	primaryLagQuery = `SELECT application_name,
+COALESCE(EXTRACT(EPOCH FROM replay_lag), 0)::float8,
+COALESCE(pg_wal_lsn_diff(pg_current_wal_lsn(), replay_lsn), 0)::int8
+FROM pg_stat_replication`
+)
+
+// replicationLag is the lag of the replicas of the last scrape.
+type replicationLag struct {
+	mu       sync.Mutex
+	replicas []replicaLag
+}
+
+// replicaLag is the lag of a replica.
+type replicaLag struct {
+	options metric.ObserveOption
+	lag     float64
+	bytes   int64
+}
+
+// CollectReplicationLag reads the replication lag every interval (defaults to 10
+// seconds) until ctx is done, which it returns the error of, and exports the
+// postgresql.replication.lag (in seconds) and postgresql.replication.lag_bytes
+// gauges. On a standby it reports the replay lag of the standby, and on a primary
+// the lag of every replica of pg_stat_replication, as of the last scrape: the
+// replicas that left pg_stat_replication are no longer reported. The replay lag of
+// a standby grows while the primary is idle. It is meant to run in its own
+// goroutine.
+func CollectReplicationLag(ctx context.Context, pool *pgxpool.Pool, interval time.Duration) error {
+	t, ok := lookup(pool.Config().ConnConfig.Tracer)
+	if !ok {
+		t = &QueryTracer{}
+	}
+
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	lag := &replicationLag{}
+
+	registration, err := t.observeReplicationLag(lag)
+	if err != nil {
+		return err
+	}
+	// stop the observation
+	defer registration.Unregister()
+
+	ticker := t.clock().NewTicker(interval)
+	// stop the ticker
+	defer ticker.Stop()
+
+	for {
+		if err := t.scrapeReplicationLag(ctx, pool, lag); err != nil && ctx.Err() == nil {
+			otel.Handle(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
+
+func (t *QueryTracer) scrapeReplicationLag(ctx context.Context, pool *pgxpool.Pool, lag *replicationLag) error {
+	// the lag is not traced
+	ctx = untraced(ctx)
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// release the connection
+	defer conn.Release()
+
+	var standby bool
+	if err := conn.QueryRow(ctx, "SELECT pg_is_in_recovery()").Scan(&standby); err != nil {
+		return err
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBName(pool.Config().ConnConfig.Database),
+	}
+	attrs = stabilize(attrs)
+	attrs = append(attrs, t.poolName()...)
+
+	if standby {
+		replica := replicaLag{}
+		if err := conn.QueryRow(ctx, standbyLagQuery).Scan(&replica.lag, &replica.bytes); err != nil {
+			return err
+		}
+
+		attrs = append(attrs, ServerRoleKey.String("standby"))
+		replica.options = metric.WithAttributes(attrs...)
+		lag.set([]replicaLag{replica})
+		return nil
+	}
+
+	rows, err := conn.Query(ctx, primaryLagQuery)
+	if err != nil {
+		return err
+	}
+	// close the rows
+	defer rows.Close()
+
+	attrs = append(attrs, ServerRoleKey.String("primary"))
+
+	replicas := []replicaLag{}
+	for rows.Next() {
+		var (
+			name    string
+			replica replicaLag
+		)
+
+		if err := rows.Scan(&name, &replica.lag, &replica.bytes); err != nil {
+			return err
+		}
+
+		replica.options = metric.WithAttributes(append(attrs, ReplicaKey.String(name))...)
+		replicas = append(replicas, replica)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	lag.set(replicas)
+	// done!
+	return nil
+}
+
+func (l *replicationLag) set(replicas []replicaLag) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.replicas = replicas
+}
+
+func (t *QueryTracer) observeReplicationLag(lag *replicationLag) (metric.Registration, error) {
+	meter := t.meter()
+
+	seconds, err := meter.Float64ObservableGauge("postgresql.replication.lag",
+		metric.WithDescription("The replay lag of a replica."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := meter.Int64ObservableGauge("postgresql.replication.lag_bytes",
+		metric.WithDescription("The amount of WAL a replica has yet to replay."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return meter.RegisterCallback(func(ctx context.Context, observer metric.Observer) error {
+		lag.mu.Lock()
+		defer lag.mu.Unlock()
+
+		for _, replica := range lag.replicas {
+			observer.ObserveFloat64(seconds, replica.lag, replica.options)
+			observer.ObserveInt64(bytes, replica.bytes, replica.options)
+		}
+		// done!
+		return nil
+	}, seconds, bytes)
+}
@@ -0,0 +1,164 @@
+package pgxotel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"syscall"
+	"time"
+
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	pgxpool "github.com/jackc/pgx/v5/pgxpool"
+	attribute "go.opentelemetry.io/otel/attribute"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// RetryAttemptKey is the attribute key for the number of an attempt.
+	RetryAttemptKey = attribute.Key("pgx.retry.attempt")
+	// RetryAttemptsKey is the attribute key for the total number of attempts.
+	RetryAttemptsKey = attribute.Key("pgx.retry.attempts")
+	// RetryOutcomeKey is the attribute key for the final outcome of a retry.
+	RetryOutcomeKey = attribute.Key("pgx.retry.outcome")
+)
+
+// RetryPolicy controls how Retry executes a function.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts (defaults to 3)
+	MaxAttempts int
+	// Backoff returns the delay before the given attempt (defaults to exponential backoff)
+	Backoff func(attempt int) time.Duration
+	// Retryable reports whether an error can be retried (defaults to serialization
+	// failures, deadlocks and connection errors)
+	Retryable func(err error) bool
+}
+
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+
+	return 3
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.Backoff != nil {
+		return p.Backoff(attempt)
+	}
+
+	return 10 * time.Millisecond << (attempt - 1)
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+
+	var perr *pgconn.PgError
+	if errors.As(err, &perr) {
+		switch perr.Code {
+		case "40001", "40P01":
+			return true
+		}
+		// connection exceptions
+		return strings.HasPrefix(perr.Code, "08")
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	return pgconn.SafeToRetry(err)
+}
+
+// Retry executes fn on a connection acquired from the pool until it succeeds, fails
+// with an error that cannot be retried or the policy is exhausted. It creates a
+// parent span with one child span per attempt.
+func Retry(ctx context.Context, pool *pgxpool.Pool, policy RetryPolicy, fn func(context.Context, *pgxpool.Conn) error) error {
+	t, ok := lookup(pool.Config().ConnConfig.Tracer)
+	if !ok {
+		t = &QueryTracer{}
+	}
+
+	recording := trace.SpanFromContext(ctx).IsRecording() && !t.DisableSpans
+
+	var span trace.Span
+	if recording {
+		ctx, span = t.start(ctx, "Retry", nil, trace.WithSpanKind(trace.SpanKindInternal))
+	}
+
+	var (
+		err      error
+		attempts int
+		outcome  = "exhausted"
+	)
+
+	for attempts < policy.attempts() {
+		if attempts > 0 {
+			if err = t.wait(ctx, policy.backoff(attempts)); err != nil {
+				outcome = "failure"
+				break
+			}
+		}
+
+		attempts++
+
+		err = t.attempt(ctx, pool, attempts, recording, fn)
+		if err == nil {
+			outcome = "success"
+			break
+		}
+
+		if !policy.retryable(err) {
+			outcome = "failure"
+			break
+		}
+	}
+
+	if recording {
+		attrs := []attribute.KeyValue{}
+		attrs = append(attrs, RetryAttemptsKey.Int(attempts))
+		attrs = append(attrs, RetryOutcomeKey.String(outcome))
+		// done
+		t.stop(ctx, span, pgconn.CommandTag{}, err, attrs)
+	}
+
+	return err
+}
+
+func (t *QueryTracer) attempt(ctx context.Context, pool *pgxpool.Pool, attempt int, recording bool, fn func(context.Context, *pgxpool.Conn) error) (err error) {
+	if recording {
+		var span trace.Span
+
+		attrs := []attribute.KeyValue{}
+		attrs = append(attrs, RetryAttemptKey.Int(attempt))
+		// prepare the span
+		ctx, span = t.start(ctx, "Attempt", attrs, trace.WithSpanKind(trace.SpanKindInternal))
+		// done!
+		defer func() { t.stop(ctx, span, pgconn.CommandTag{}, err, nil) }()
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// release the connection
+	defer conn.Release()
+
+	return fn(ctx, conn)
+}
+
+func (t *QueryTracer) wait(ctx context.Context, delay time.Duration) error {
+	done := make(chan struct{})
+
+	timer := t.clock().AfterFunc(delay, func() { close(done) })
+	// stop the timer
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+		return nil
+	}
+}
@@ -0,0 +1,148 @@
+package pgxotel_test
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pgx-contrib/pgxotel"
+	"github.com/pgx-contrib/pgxotel/internal/fakepg"
+	"github.com/pgx-contrib/pgxotel/pgxoteltest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func ExampleRetry() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	config.ConnConfig.Tracer = &pgxotel.QueryTracer{
+		Name: "example-api",
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the pool
+	defer pool.Close()
+
+	policy := pgxotel.RetryPolicy{
+		MaxAttempts: 5,
+	}
+
+	err = pgxotel.Retry(context.TODO(), pool, policy, func(ctx context.Context, conn *pgxpool.Conn) error {
+		_, err := conn.Exec(ctx, "UPDATE customer SET visits = visits + 1")
+		return err
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// open opens a pool of connections to the server with the tracer, until the test
+// ends.
+func open(t *testing.T, server *fakepg.Server, tracer *pgxotel.QueryTracer) *pgxpool.Pool {
+	t.Helper()
+
+	config, err := pgxpool.ParseConfig(fakepg.ConnString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.ConnConfig.DialFunc = server.Dial
+	config.ConnConfig.Tracer = tracer
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// close the pool
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+func TestRetry(t *testing.T) {
+	recorder := pgxoteltest.NewRecorder()
+	tracer := pgxotel.NewQueryTracer("example-api", recorder.Option())
+
+	failures := 2
+	server := &fakepg.Server{
+		Handler: func(sql string, args []string) fakepg.Result {
+			// the statement is described before it is executed
+			if args == nil || failures == 0 {
+				return fakepg.Result{}
+			}
+
+			failures--
+			return fakepg.Result{Err: &pgconn.PgError{Code: "40001", Message: "could not serialize access"}}
+		},
+	}
+
+	pool := open(t, server, tracer)
+
+	policy := pgxotel.RetryPolicy{
+		MaxAttempts: 5,
+		Backoff:     func(int) time.Duration { return 0 },
+	}
+
+	ctx, span := recorder.Start(context.TODO(), "test")
+	err := pgxotel.Retry(ctx, pool, policy, func(ctx context.Context, conn *pgxpool.Conn) error {
+		_, err := conn.Exec(ctx, "UPDATE customer SET visits = visits + $1", "1")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	span.End()
+
+	spans := recorder.Spans()
+	// the retry is a child of the test span
+	retry := lookup(t, spans, "Retry")
+	if retry.Parent.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("expected the Retry span to be a child of the test span")
+	}
+
+	for _, attr := range []attribute.KeyValue{pgxotel.RetryAttemptsKey.Int(3), pgxotel.RetryOutcomeKey.String("success")} {
+		if value, ok := retry.Attribute(attr.Key); !ok || value != attr.Value {
+			t.Errorf("expected the %s attribute %v of the Retry span, got %v", attr.Key, attr.Value.Emit(), value.Emit())
+		}
+	}
+
+	attempts := named(spans, "Attempt")
+	if len(attempts) != 3 {
+		t.Fatalf("expected 3 Attempt spans, got %d", len(attempts))
+	}
+
+	for index, attempt := range attempts {
+		if attempt.Parent.SpanID() != retry.SpanContext.SpanID() {
+			t.Errorf("expected the attempt %d to be a child of the Retry span", index+1)
+		}
+
+		if value, _ := attempt.Attribute(pgxotel.RetryAttemptKey); value.AsInt64() != int64(index+1) {
+			t.Errorf("expected the attempt %d, got %v", index+1, value.Emit())
+		}
+		// the last attempt succeeded
+		status := codes.Error
+		if index == len(attempts)-1 {
+			status = codes.Unset
+		}
+
+		if attempt.Status != status {
+			t.Errorf("expected the status %v of the attempt %d, got %v", status, index+1, attempt.Status)
+		}
+
+		queries := pgxoteltest.QuerySpans(spans, pgxoteltest.WithStatement("UPDATE customer SET visits = visits + $1"))
+		if !slices.ContainsFunc(queries, func(query pgxoteltest.Span) bool { return query.Parent.SpanID() == attempt.SpanContext.SpanID() }) {
+			t.Errorf("expected the query of the attempt %d", index+1)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package pgxotel
+
+import (
+	"context"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	attribute "go.opentelemetry.io/otel/attribute"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TimeToFirstRowKey is the attribute key for the time in seconds until the first row was returned.
+	TimeToFirstRowKey = attribute.Key("db.response.time_to_first_row")
+	// FetchedRowsKey is the attribute key for the number of rows iterated by the caller.
+	FetchedRowsKey = attribute.Key("db.response.fetched_rows")
+	// ScannedRowsKey is the attribute key for the number of rows scanned by CollectRows.
+	ScannedRowsKey = attribute.Key("db.response.scanned_rows")
+)
+
+// Querier is the interface implemented by pgx.Conn, pgx.Tx and pgxpool.Pool.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// fetch tracks the consumption of the rows returned by Query.
+type fetch struct {
+	// start is the time the query was issued, once the connection was acquired
+	start time.Time
+	// span is the query span created by the tracer
+	span trace.Span
+	// tracer is the tracer that created the span
+	tracer *QueryTracer
+	// first reports whether the first row was returned
+	first bool
+	// ctx is the context of the Fetch span
+	ctx context.Context
+	// child is the Fetch span, which ends before the query span
+	child trace.Span
+	// count is the number of rows iterated by the caller
+	count int64
+}
+
+type fetchKey struct{}
+
+func fetchFrom(ctx context.Context) *fetch {
+	f, _ := ctx.Value(fetchKey{}).(*fetch)
+	return f
+}
+
+// observe returns the time to first row attribute, once.
+func (f *fetch) observe() []attribute.KeyValue {
+	if f.first {
+		return nil
+	}
+
+	f.first = true
+	// done!
+	return []attribute.KeyValue{
+		TimeToFirstRowKey.Float64(f.tracer.since(f.start).Seconds()),
+	}
+}
+
+// end ends the Fetch span, if any, before the query span.
+func (f *fetch) end(tag pgconn.CommandTag, err error) {
+	if f.child == nil {
+		return
+	}
+
+	attrs := []attribute.KeyValue{}
+	attrs = append(attrs, FetchedRowsKey.Int64(f.count))
+	// done!
+	f.tracer.stop(f.ctx, f.child, tag, err, attrs)
+	f.child = nil
+}
+
+// Query executes the query on q and returns rows that record the time until the
+// first row was returned on the query span, if any row was read. Iterating the rows is traced by a
+// Fetch child span that ends when the rows are closed, right before the query
+// span.
+func Query(ctx context.Context, q Querier, sql string, args ...any) (pgx.Rows, error) {
+	f := &fetch{}
+	// the tracer registers the query span
+	ctx = context.WithValue(ctx, fetchKey{}, f)
+
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return rows, err
+	}
+
+	r := &tracedRows{Rows: rows, fetch: f}
+	// prepare the fetch span
+	if f.span != nil && f.span.IsRecording() {
+		ctx = trace.ContextWithSpan(ctx, f.span)
+		f.ctx, f.child = f.tracer.start(ctx, "Fetch", nil)
+	}
+
+	return r, nil
+}
+
+type tracedRows struct {
+	pgx.Rows
+	fetch *fetch
+}
+
+// Next implements pgx.Rows.
+func (r *tracedRows) Next() bool {
+	next := r.Rows.Next()
+	// the query span has ended when there are no more rows
+	if next && r.fetch.span != nil {
+		r.fetch.tracer.annotate(r.fetch.span, r.fetch.observe()...)
+	}
+
+	if next {
+		r.fetch.count++
+	} else {
+		r.fetch.end(r.Rows.CommandTag(), r.Rows.Err())
+	}
+
+	return next
+}
+
+// Close implements pgx.Rows.
+func (r *tracedRows) Close() {
+	// the tracer ends the fetch span when the rows are closed
+	r.Rows.Close()
+	r.fetch.end(r.Rows.CommandTag(), r.Rows.Err())
+}
+
+// CollectRows collects the values returned by fn for every row like
+// pgx.CollectRows. The scan errors are recorded on the Fetch span of rows returned
+// by Query, or on the span of ctx together with the number of scanned rows, since
+// they happen after the query span has ended.
+func CollectRows[T any](ctx context.Context, rows pgx.Rows, fn pgx.RowToFunc[T]) ([]T, error) {
+	// close the rows
+	defer rows.Close()
+
+	span := trace.SpanFromContext(ctx)
+	// the fetch span records the number of rows
+	traced, ok := rows.(*tracedRows)
+	if ok && traced.fetch.child != nil {
+		span = traced.fetch.child
+	}
+
+	values := []T{}
+	for rows.Next() {
+		value, err := fn(rows)
+		if err != nil {
+			span.RecordError(err)
+			if !ok {
+				span.SetAttributes(ScannedRowsKey.Int(len(values)))
+			}
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	if !ok {
+		span.SetAttributes(ScannedRowsKey.Int(len(values)))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
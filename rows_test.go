@@ -0,0 +1,142 @@
+package pgxotel_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pgx-contrib/pgxotel"
+	"github.com/pgx-contrib/pgxotel/internal/fakepg"
+	"github.com/pgx-contrib/pgxotel/pgxoteltest"
+)
+
+func ExampleQuery() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	config.ConnConfig.Tracer = &pgxotel.QueryTracer{
+		Name: "example-api",
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the pool
+	defer pool.Close()
+
+	rows, err := pgxotel.Query(context.TODO(), pool, "SELECT first_name FROM customer")
+	if err != nil {
+		panic(err)
+	}
+	// close the rows
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			panic(err)
+		}
+
+		fmt.Println(name)
+	}
+}
+
+func ExampleCollectRows() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	config.ConnConfig.Tracer = &pgxotel.QueryTracer{
+		Name: "example-api",
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the pool
+	defer pool.Close()
+
+	rows, err := pgxotel.Query(context.TODO(), pool, "SELECT first_name FROM customer")
+	if err != nil {
+		panic(err)
+	}
+
+	names, err := pgxotel.CollectRows(context.TODO(), rows, pgx.RowTo[string])
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(names)
+}
+
+func TestQuery(t *testing.T) {
+	recorder := pgxoteltest.NewRecorder()
+	tracer := pgxotel.NewQueryTracer("example-api", recorder.Option())
+
+	server := &fakepg.Server{
+		Handler: func(sql string, _ []string) fakepg.Result {
+			if sql == "SELECT name FROM vendor" {
+				return fakepg.Result{Columns: []string{"name"}}
+			}
+
+			return fakepg.Result{Columns: []string{"name"}, Rows: [][]string{{"alice"}, {"bob"}, {"carol"}}}
+		},
+	}
+
+	ctx, span := recorder.Start(context.TODO(), "test")
+	conn := connect(t, ctx, server, tracer)
+
+	for _, sql := range []string{"SELECT name FROM customer", "SELECT name FROM vendor"} {
+		rows, err := pgxotel.Query(ctx, conn, sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(names) != len(server.Handler(sql, nil).Rows) {
+			t.Errorf("expected the rows of %q, got %q", sql, names)
+		}
+	}
+
+	span.End()
+
+	spans := recorder.Spans()
+	fetches := named(spans, "Fetch")
+	if len(fetches) != 2 {
+		t.Fatalf("expected 2 Fetch spans, got %d", len(fetches))
+	}
+
+	for index, sql := range []string{"SELECT name FROM customer", "SELECT name FROM vendor"} {
+		// the rows are fetched within the query
+		fetch := fetches[index]
+		index := slices.IndexFunc(spans, func(span pgxoteltest.Span) bool { return span.SpanContext.SpanID() == fetch.Parent.SpanID() })
+		if index < 0 || spans[index].Statement != sql || spans[index].Operation != "SELECT" {
+			t.Errorf("expected the Fetch span of %q to be a child of the query span", sql)
+			continue
+		}
+
+		query := spans[index]
+
+		count := int64(len(server.Handler(sql, nil).Rows))
+		if value, _ := fetch.Attribute(pgxotel.FetchedRowsKey); value.AsInt64() != count {
+			t.Errorf("expected %d fetched rows of %q, got %v", count, sql, value.Emit())
+		}
+		// the time to first row is only recorded when a row was read
+		if _, ok := query.Attribute(pgxotel.TimeToFirstRowKey); ok != (count > 0) {
+			t.Errorf("expected the time to first row of %q to be recorded: %v", sql, count > 0)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package pgxotel
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	attribute "go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	stable "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// stability selects the database semantic conventions that are emitted.
+type stability int
+
+const (
+	// stabilityOld emits the experimental attributes.
+	stabilityOld stability = iota
+	// stabilityNew emits the stable attributes.
+	stabilityNew
+	// stabilityDup emits both the experimental and the stable attributes.
+	stabilityDup
+)
+
+// semconvStability reads the database opt-in of OTEL_SEMCONV_STABILITY_OPT_IN.
+var semconvStability = sync.OnceValue(func() stability {
+	mode := stabilityOld
+
+	for _, value := range strings.Split(os.Getenv("OTEL_SEMCONV_STABILITY_OPT_IN"), ",") {
+		switch strings.TrimSpace(value) {
+		case "database/dup":
+			return stabilityDup
+		case "database":
+			mode = stabilityNew
+		}
+	}
+
+	return mode
+})
+
+// stableKeys maps the experimental attribute keys to the stable ones. The keys
+// mapped to an empty key have no stable equivalent.
+var stableKeys = map[attribute.Key]attribute.Key{
+	semconv.DBNameKey:             stable.DBNamespaceKey,
+	semconv.DBStatementKey:        stable.DBQueryTextKey,
+	semconv.DBOperationKey:        stable.DBOperationNameKey,
+	semconv.DBSQLTableKey:         stable.DBCollectionNameKey,
+	semconv.NetSockPeerAddrKey:    stable.NetworkPeerAddressKey,
+	semconv.NetSockPeerPortKey:    stable.NetworkPeerPortKey,
+	semconv.DBUserKey:             "",
+	semconv.DBConnectionStringKey: "",
+}
+
+// stabilize rewrites the attributes according to OTEL_SEMCONV_STABILITY_OPT_IN.
+// The attributes are rewritten in place unless both conventions are emitted.
+func stabilize(attrs []attribute.KeyValue) []attribute.KeyValue {
+	switch semconvStability() {
+	case stabilityNew:
+		valid := attrs[:0]
+		for _, attr := range attrs {
+			key, ok := stableKeys[attr.Key]
+			switch {
+			case !ok:
+				valid = append(valid, attr)
+			case key != "":
+				valid = append(valid, attribute.KeyValue{Key: key, Value: attr.Value})
+			}
+		}
+
+		return valid
+	case stabilityDup:
+		for _, attr := range attrs {
+			if key := stableKeys[attr.Key]; key != "" {
+				attrs = append(attrs, attribute.KeyValue{Key: key, Value: attr.Value})
+			}
+		}
+
+		return attrs
+	default:
+		return attrs
+	}
+}
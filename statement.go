@@ -0,0 +1,447 @@
+package pgxotel
+
+import (
+	"strings"
+
+	attribute "go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+// StatementSanitizer rewrites a statement before it is recorded as the
+// db.statement attribute. The result is cached per statement, so Sanitize must
+// return the same value for the same statement.
+type StatementSanitizer interface {
+	Sanitize(query string) string
+}
+
+// StatementSanitizerFunc adapts a function to the StatementSanitizer interface.
+type StatementSanitizerFunc func(query string) string
+
+// Sanitize implements StatementSanitizer.
+func (fn StatementSanitizerFunc) Sanitize(query string) string {
+	return fn(query)
+}
+
+// CommentSanitizer strips the comments and collapses the whitespace of a
+// statement. String literals, quoted identifiers and dollar-quoted bodies are
+// kept as they are. It is the default StatementSanitizer.
+type CommentSanitizer struct{}
+
+// Sanitize implements StatementSanitizer.
+func (CommentSanitizer) Sanitize(query string) string {
+	return SanitizeStatement(query)
+}
+
+// SanitizeStatement returns the statement as recorded by default as db.statement:
+// without its comments and with its whitespace collapsed (see CommentSanitizer).
+func SanitizeStatement(sql string) string {
+	return collapse(sql, false)
+}
+
+// RawSanitizer records the statements as they are.
+type RawSanitizer struct{}
+
+// Sanitize implements StatementSanitizer.
+func (RawSanitizer) Sanitize(query string) string {
+	return query
+}
+
+// LiteralSanitizer strips the comments, collapses the whitespace and replaces the
+// string, dollar-quoted and numeric literals of a statement with ?, so that no
+// value reaches the telemetry backend. The lists of placeholders of IN and VALUES
+// are collapsed to (...), so that the statements group regardless of the length
+// of the lists.
+type LiteralSanitizer struct{}
+
+// Sanitize implements StatementSanitizer.
+func (LiteralSanitizer) Sanitize(query string) string {
+	return collapseLists(collapse(query, true))
+}
+
+// StatementMode selects how the statements are recorded as db.statement.
+type StatementMode int
+
+const (
+	// StatementCollapsed strips the comments and collapses the whitespace (see
+	// CommentSanitizer).
+	StatementCollapsed StatementMode = iota
+	// StatementRaw records the statements as they are (see RawSanitizer).
+	StatementRaw
+	// StatementNormalized also replaces the literals with ? (see LiteralSanitizer).
+	StatementNormalized
+)
+
+// Sanitizer returns the StatementSanitizer of the mode.
+func (m StatementMode) Sanitizer() StatementSanitizer {
+	switch m {
+	case StatementRaw:
+		return RawSanitizer{}
+	case StatementNormalized:
+		return LiteralSanitizer{}
+	default:
+		return CommentSanitizer{}
+	}
+}
+
+// collapse strips the comments and collapses the whitespace of the query, and
+// replaces its literals with ? if literals is set.
+func collapse(query string, literals bool) string {
+	builder := &strings.Builder{}
+	builder.Grow(len(query))
+
+	space := false
+	// scan the query and fill the builder
+	for index := 0; index < len(query); {
+		switch c := query[index]; {
+		case isSpace(c):
+			space = true
+			index++
+			continue
+		case strings.HasPrefix(query[index:], "--"):
+			space = true
+			index = lineComment(query, index)
+			continue
+		case strings.HasPrefix(query[index:], "/*"):
+			space = true
+			index = blockComment(query, index)
+			continue
+		}
+
+		if space && builder.Len() > 0 {
+			builder.WriteByte(' ')
+		}
+
+		space = false
+
+		var (
+			next    int
+			literal bool
+		)
+		// the literals depend on what precedes them once sanitized
+		before := builder.String()
+		switch c := query[index]; {
+		case c == '\'':
+			next, literal = quoted(query, index, '\'', escaped(before)), true
+		case c == '"':
+			next = quoted(query, index, '"', false)
+		case c == '$':
+			next = dollarQuoted(query, index, before)
+			literal = next > index+1
+		case isDigit(c) && (len(before) == 0 || !isIdentifier(before[len(before)-1])):
+			next = number(query, index)
+			// the digits of 1abc or 0$$ are not a literal
+			if literal = next >= len(query) || !isIdentifier(query[next]); !literal {
+				for next < len(query) && isIdentifier(query[next]) {
+					next++
+				}
+			}
+		default:
+			next = index + 1
+		}
+
+		if literal && literals {
+			builder.WriteByte('?')
+		} else {
+			builder.WriteString(query[index:next])
+		}
+
+		index = next
+	}
+
+	// done
+	return builder.String()
+}
+
+// collapseLists collapses the lists of placeholders that follow the IN and VALUES
+// keywords of a normalized query to (...).
+func collapseLists(query string) string {
+	if !strings.Contains(query, "(") {
+		return query
+	}
+
+	builder := &strings.Builder{}
+	builder.Grow(len(query))
+
+	for index := 0; index < len(query); {
+		c := query[index]
+		switch {
+		case c == '"':
+			next := quoted(query, index, '"', false)
+			builder.WriteString(query[index:next])
+			index = next
+			continue
+		case !isIdentifier(c) || c == '$' || isDigit(c):
+			builder.WriteByte(c)
+			index++
+			continue
+		}
+
+		next := index
+		for next < len(query) && isIdentifier(query[next]) {
+			next++
+		}
+
+		word := query[index:next]
+		builder.WriteString(word)
+		index = next
+
+		switch {
+		case strings.EqualFold(word, "IN"):
+			if end, ok := placeholders(query, index); ok {
+				builder.WriteString(" (...)")
+				index = end
+			}
+		case strings.EqualFold(word, "VALUES"):
+			if end, ok := placeholders(query, index); ok {
+				builder.WriteString(" (...)")
+				index = end
+				// the rows of a multi-row insert
+				for {
+					next := skipSpace(query, index)
+					if next >= len(query) || query[next] != ',' {
+						break
+					}
+
+					end, ok := placeholders(query, next+1)
+					if !ok {
+						break
+					}
+
+					index = end
+				}
+			}
+		}
+	}
+
+	// done
+	return builder.String()
+}
+
+// placeholders returns the end of the parenthesized list of placeholders (? or
+// $N) at index, if any.
+func placeholders(query string, index int) (int, bool) {
+	index = skipSpace(query, index)
+	if index >= len(query) || query[index] != '(' {
+		return 0, false
+	}
+
+	for {
+		index = skipSpace(query, index+1)
+		switch {
+		case index < len(query) && query[index] == '?':
+			index++
+		case index+1 < len(query) && query[index] == '$' && isDigit(query[index+1]):
+			index++
+			for index < len(query) && isDigit(query[index]) {
+				index++
+			}
+		default:
+			return 0, false
+		}
+
+		index = skipSpace(query, index)
+		if index >= len(query) {
+			return 0, false
+		}
+
+		switch query[index] {
+		case ')':
+			return index + 1, true
+		case ',':
+			continue
+		default:
+			return 0, false
+		}
+	}
+}
+
+func skipSpace(query string, index int) int {
+	for index < len(query) && isSpace(query[index]) {
+		index++
+	}
+
+	return index
+}
+
+// split splits a multi-statement query on the semicolons outside of comments,
+// literals and quoted identifiers. Empty statements are omitted.
+func split(query string) []string {
+	statements := []string{}
+
+	add := func(statement string) {
+		if (CommentSanitizer{}).Sanitize(statement) != "" {
+			statements = append(statements, strings.TrimSpace(statement))
+		}
+	}
+
+	begin := 0
+	for index := 0; index < len(query); {
+		switch c := query[index]; {
+		case strings.HasPrefix(query[index:], "--"):
+			index = lineComment(query, index)
+		case strings.HasPrefix(query[index:], "/*"):
+			index = blockComment(query, index)
+		case c == '\'':
+			index = quoted(query, index, '\'', escaped(query[:index]))
+		case c == '"':
+			index = quoted(query, index, '"', false)
+		case c == '$':
+			index = dollarQuoted(query, index, query[:index])
+		case c == ';':
+			add(query[begin:index])
+			index++
+			begin = index
+		default:
+			index++
+		}
+	}
+
+	add(query[begin:])
+	// done
+	return statements
+}
+
+// lineComment returns the end of the line comment at index.
+func lineComment(query string, index int) int {
+	if end := strings.IndexByte(query[index:], '\n'); end >= 0 {
+		return index + end
+	}
+
+	return len(query)
+}
+
+// blockComment returns the end of the possibly nested block comment at index.
+func blockComment(query string, index int) int {
+	depth := 0
+
+	for index < len(query) {
+		switch {
+		case strings.HasPrefix(query[index:], "/*"):
+			depth++
+			index += 2
+		case strings.HasPrefix(query[index:], "*/"):
+			depth--
+			index += 2
+
+			if depth == 0 {
+				return index
+			}
+		default:
+			index++
+		}
+	}
+
+	return len(query)
+}
+
+// quoted returns the end of the literal or identifier quoted by quote at index.
+func quoted(query string, index int, quote byte, backslash bool) int {
+	for index++; index < len(query); index++ {
+		switch query[index] {
+		case '\\':
+			if backslash {
+				index++
+			}
+		case quote:
+			// the quote is escaped by doubling it
+			if index+1 < len(query) && query[index+1] == quote {
+				index++
+				continue
+			}
+
+			return index + 1
+		}
+	}
+
+	return len(query)
+}
+
+// number returns the end of the numeric literal at index.
+func number(query string, index int) int {
+	for index < len(query) {
+		switch c := query[index]; {
+		case isDigit(c) || c == '.' || c == '_':
+			index++
+		case (c == 'e' || c == 'E') && index+1 < len(query):
+			index++
+			// the exponent may be signed
+			if query[index] == '+' || query[index] == '-' {
+				index++
+			}
+		default:
+			return index
+		}
+	}
+
+	return len(query)
+}
+
+// escaped reports whether the literal that follows before is an escape string
+// (E'...').
+func escaped(before string) bool {
+	size := len(before)
+	if size == 0 || (before[size-1] != 'E' && before[size-1] != 'e') {
+		return false
+	}
+
+	return size == 1 || !isIdentifier(before[size-2])
+}
+
+// dollarQuoted returns the end of the dollar-quoted body at index. Parameters
+// such as $1 and identifiers containing $ are not dollar-quoted.
+func dollarQuoted(query string, index int, before string) int {
+	if len(before) > 0 && isIdentifier(before[len(before)-1]) {
+		return index + 1
+	}
+
+	end := index + 1
+	for end < len(query) && query[end] != '$' && isIdentifier(query[end]) && (end > index+1 || !isDigit(query[end])) {
+		end++
+	}
+
+	if end >= len(query) || query[end] != '$' {
+		return index + 1
+	}
+
+	tag := query[index : end+1]
+	if body := strings.Index(query[end+1:], tag); body >= 0 {
+		return end + 1 + body + len(tag)
+	}
+
+	return len(query)
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\f', '\v':
+		return true
+	}
+
+	return false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentifier(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'z') || c >= 0x80
+}
+
+// statement returns the sanitized statement attribute of the query.
+func (t *QueryTracer) statement(query string) attribute.KeyValue {
+	return t.statements.get(query, t.sanitize)
+}
+
+func (t *QueryTracer) sanitize(query string) attribute.KeyValue {
+	var sanitizer StatementSanitizer = CommentSanitizer{}
+	if t.Sanitizer != nil {
+		sanitizer = t.Sanitizer
+	}
+
+	if hinted(query) {
+		query = t.hint(query).query
+	}
+
+	return semconv.DBStatement(sanitizer.Sanitize(query))
+}
@@ -0,0 +1,137 @@
+package pgxotel_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pgx-contrib/pgxotel"
+	"github.com/pgx-contrib/pgxotel/pgxoteltest"
+)
+
+func ExampleCommentSanitizer() {
+	sanitizer := pgxotel.CommentSanitizer{}
+
+	fmt.Println(sanitizer.Sanitize(`
+		-- name: GetCustomer
+		SELECT first_name /* the name */, '--not a comment'
+		FROM customer
+		WHERE body = $$ -- kept $$ AND id = $1
+	`))
+
+	// Output: SELECT first_name , '--not a comment' FROM customer WHERE body = $$ -- kept $$ AND id = $1
+}
+
+func ExampleSanitizeStatement() {
+	fmt.Println(pgxotel.SanitizeStatement("SELECT *\n\tFROM customer -- by id\n\tWHERE id = $1"))
+
+	// Output: SELECT * FROM customer WHERE id = $1
+}
+
+func ExampleLiteralSanitizer() {
+	sanitizer := pgxotel.LiteralSanitizer{}
+
+	fmt.Println(sanitizer.Sanitize(`
+		SELECT first_name FROM customer
+		WHERE last_name = 'Doe' AND age > 42 AND note = $$ secret $$ AND id = $1
+	`))
+
+	// Output: SELECT first_name FROM customer WHERE last_name = ? AND age > ? AND note = ? AND id = $1
+}
+
+func ExampleFingerprint() {
+	a := pgxotel.Fingerprint("SELECT * FROM customer WHERE id IN ($1, $2, $3)")
+	b := pgxotel.Fingerprint("SELECT * FROM customer -- by id\nWHERE id IN ($1)")
+	// the literals and the parameters share the placeholder
+	c := pgxotel.Fingerprint("SELECT * FROM customer WHERE id IN (1, 2)")
+
+	fmt.Println(a == b, a == c)
+	fmt.Println(pgxotel.LiteralSanitizer{}.Sanitize("INSERT INTO customer VALUES (1, 'John'), (2, 'Jane')"))
+
+	// Output:
+	// true true
+	// INSERT INTO customer VALUES (...)
+}
+
+func ExampleOperationFromSQL() {
+	fmt.Println(pgxotel.OperationFromSQL("/* name: ListCustomers */ (SELECT * FROM customer)"))
+	fmt.Println(pgxotel.OperationFromSQL("create table customer (id int)"))
+	fmt.Println(pgxotel.OperationFromCommandTag(pgconn.NewCommandTag("INSERT 0 1")))
+
+	// Output:
+	// SELECT
+	// CREATE
+	// INSERT
+}
+
+func FuzzCommentSanitizer(f *testing.F) {
+	f.Add("SELECT 1")
+	f.Add("SELECT 1 -- comment\nFROM t")
+	f.Add("SELECT '--', \"a--b\" /* c /* nested */ c */ FROM t")
+	f.Add("SELECT E'\\'--' FROM t")
+	f.Add("SELECT $tag$ -- $$ /* */ $tag$, $1, a$b$ FROM t")
+	f.Add("SELECT '")
+	f.Add("/* unterminated")
+
+	sanitizer := pgxotel.CommentSanitizer{}
+
+	f.Fuzz(func(t *testing.T, query string) {
+		sanitized := sanitizer.Sanitize(query)
+
+		if len(sanitized) > len(query) {
+			t.Errorf("sanitized statement %q is longer than %q", sanitized, query)
+		}
+
+		if again := sanitizer.Sanitize(sanitized); again != sanitized {
+			t.Errorf("sanitize is not idempotent: %q, then %q", sanitized, again)
+		}
+	})
+}
+
+func FuzzLiteralSanitizer(f *testing.F) {
+	f.Add("SELECT 1")
+	f.Add("SELECT 1.5e-3, 'a''b', E'\\'', $$x$$, $1, t1.c2 FROM t")
+	f.Add("SELECT $tag$ -- $$ /* */ $tag$ FROM t")
+	f.Add("0$$0")
+	f.Add("0.$$")
+	f.Add("$$$$0")
+	f.Add("SELECT * FROM t WHERE id IN ($1, $2) AND v IN (1,2,3) AND (a, b) IN ((?, ?))")
+	f.Add("INSERT INTO t VALUES ($1, $2), ($3, $4) RETURNING id")
+
+	sanitizer := pgxotel.LiteralSanitizer{}
+
+	f.Fuzz(func(t *testing.T, query string) {
+		sanitized := sanitizer.Sanitize(query)
+
+		if again := sanitizer.Sanitize(sanitized); again != sanitized {
+			t.Errorf("sanitize is not idempotent: %q, then %q", sanitized, again)
+		}
+	})
+}
+
+func FuzzTableName(f *testing.F) {
+	f.Add("SELECT * FROM customer")
+	f.Add("SELECT * FROM ONLY public.\"Customer\" WHERE id = $1")
+	f.Add("INSERT INTO t (a) SELECT a FROM (SELECT 1 AS a) s")
+	f.Add("SELECT 1 AS “x” FROM t")
+	f.Add("SELECT price£ FROM t")
+	f.Add("SELECT \xe2 FROM t.")
+
+	recorder := pgxoteltest.NewRecorder()
+	tracer := pgxotel.NewQueryTracer("example-api", recorder.Option())
+
+	f.Fuzz(func(t *testing.T, query string) {
+		recorder.Reset()
+
+		ctx, span := recorder.Start(context.TODO(), "test")
+		tracer.Record(ctx, pgxotel.Operation{Type: pgxotel.OperationQuery, SQL: query}, nil)
+		span.End()
+
+		for _, span := range recorder.Spans() {
+			if len(span.Table) > len(query) {
+				t.Errorf("table %q is longer than %q", span.Table, query)
+			}
+		}
+	})
+}
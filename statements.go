@@ -0,0 +1,138 @@
+package pgxotel
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	pgxpool "github.com/jackc/pgx/v5/pgxpool"
+	otel "go.opentelemetry.io/otel"
+	attribute "go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// StatementCallsKey is the attribute key for the number of calls of a statement
+	// recorded by pg_stat_statements.
+	StatementCallsKey = attribute.Key("db.postgresql.statements.calls")
+	// StatementMeanTimeKey is the attribute key for the mean execution time in
+	// milliseconds of a statement recorded by pg_stat_statements.
+	StatementMeanTimeKey = attribute.Key("db.postgresql.statements.mean_exec_time")
+	// StatementTotalTimeKey is the attribute key for the total execution time in
+	// milliseconds of a statement recorded by pg_stat_statements.
+	StatementTotalTimeKey = attribute.Key("db.postgresql.statements.total_exec_time")
+)
+
+// statementStatsQuery reads the most called statements of the connected database.
+const statementStatsQuery = `SELECT query, calls, total_exec_time FROM pg_stat_statements
+WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+ORDER BY calls DESC LIMIT $1`
+
+// StatementStats caches the pg_stat_statements rows of the most called statements,
+// keyed by their Fingerprint, so that the tracer records the server-side
+// statistics of a statement on its query spans (see
+// QueryTracer.StatementStats). The zero value is ready to use.
+type StatementStats struct {
+	entries atomic.Pointer[map[string]statementStat]
+}
+
+// statementStat is the server-side statistics of a statement.
+type statementStat struct {
+	calls int64
+	total float64
+}
+
+// Collect refreshes the cache every interval (defaults to one minute) until ctx is
+// done, which it returns the error of. It is meant to run in its own goroutine.
+func (s *StatementStats) Collect(ctx context.Context, pool *pgxpool.Pool, interval time.Duration) error {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	t, ok := lookup(pool.Config().ConnConfig.Tracer)
+	if !ok {
+		t = &QueryTracer{}
+	}
+
+	ticker := t.clock().NewTicker(interval)
+	// stop the ticker
+	defer ticker.Stop()
+
+	for {
+		if err := s.Refresh(ctx, pool); err != nil && ctx.Err() == nil {
+			otel.Handle(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
+
+// Refresh reads the pg_stat_statements rows of the database of the pool. The
+// pg_stat_statements extension must be installed.
+func (s *StatementStats) Refresh(ctx context.Context, pool *pgxpool.Pool) error {
+	// the statistics are not traced
+	rows, err := pool.Query(untraced(ctx), statementStatsQuery, memoCapacity)
+	if err != nil {
+		return err
+	}
+	// close the rows
+	defer rows.Close()
+
+	entries := map[string]statementStat{}
+
+	for rows.Next() {
+		var (
+			query string
+			stat  statementStat
+		)
+
+		if err := rows.Scan(&query, &stat.calls, &stat.total); err != nil {
+			return err
+		}
+
+		// the statement of several users share the fingerprint
+		fingerprint := Fingerprint(query)
+		entry := entries[fingerprint]
+		entry.calls += stat.calls
+		entry.total += stat.total
+		entries[fingerprint] = entry
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.entries.Store(&entries)
+	// done!
+	return nil
+}
+
+// lookup returns the attributes of the statistics of the fingerprint, if any.
+func (s *StatementStats) lookup(fingerprint string) []attribute.KeyValue {
+	entries := s.entries.Load()
+	if entries == nil {
+		return nil
+	}
+
+	stat, ok := (*entries)[fingerprint]
+	if !ok || stat.calls == 0 {
+		return nil
+	}
+
+	return []attribute.KeyValue{
+		StatementCallsKey.Int64(stat.calls),
+		StatementMeanTimeKey.Float64(stat.total / float64(stat.calls)),
+		StatementTotalTimeKey.Float64(stat.total),
+	}
+}
+
+func (t *QueryTracer) statementStats(query string) []attribute.KeyValue {
+	if t.StatementStats == nil || t.Minimal {
+		return nil
+	}
+
+	return t.StatementStats.lookup(t.fingerprints.get(query, Fingerprint))
+}
@@ -0,0 +1,170 @@
+package pgxotel
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	pgxpool "github.com/jackc/pgx/v5/pgxpool"
+	otel "go.opentelemetry.io/otel"
+	attribute "go.opentelemetry.io/otel/attribute"
+	metric "go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+// databaseStatsQuery reads the statistics of the connected database.
+const databaseStatsQuery = `SELECT xact_commit, xact_rollback, blks_hit, blks_read, deadlocks, temp_bytes
+FROM pg_stat_database WHERE datname = current_database()`
+
+// databaseStats is the last snapshot of pg_stat_database.
+type databaseStats struct {
+	mu sync.Mutex
+	ok bool
+
+	commits   int64
+	rollbacks int64
+	hits      int64
+	reads     int64
+	deadlocks int64
+	temp      int64
+}
+
+// CollectDatabaseStats reads pg_stat_database for the database of the pool every
+// interval (defaults to one minute) until ctx is done, which it returns the error
+// of, and exports the postgresql.commits, postgresql.rollbacks,
+// postgresql.blks_hit, postgresql.blks_read, postgresql.deadlocks and
+// postgresql.temp.io counters. It is meant to run in its own goroutine.
+func CollectDatabaseStats(ctx context.Context, pool *pgxpool.Pool, interval time.Duration) error {
+	t, ok := lookup(pool.Config().ConnConfig.Tracer)
+	if !ok {
+		t = &QueryTracer{}
+	}
+
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	stats := &databaseStats{}
+
+	registration, err := t.observeDatabaseStats(pool, stats)
+	if err != nil {
+		return err
+	}
+	// stop the observation
+	defer registration.Unregister()
+
+	ticker := t.clock().NewTicker(interval)
+	// stop the ticker
+	defer ticker.Stop()
+
+	for {
+		if err := stats.scrape(ctx, pool); err != nil && ctx.Err() == nil {
+			otel.Handle(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
+
+func (s *databaseStats) scrape(ctx context.Context, pool *pgxpool.Pool) error {
+	var next databaseStats
+	// the statistics are not traced
+	err := pool.QueryRow(untraced(ctx), databaseStatsQuery).Scan(
+		&next.commits, &next.rollbacks, &next.hits, &next.reads, &next.deadlocks, &next.temp,
+	)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ok = true
+	s.commits, s.rollbacks = next.commits, next.rollbacks
+	s.hits, s.reads = next.hits, next.reads
+	s.deadlocks, s.temp = next.deadlocks, next.temp
+	// done!
+	return nil
+}
+
+func (t *QueryTracer) observeDatabaseStats(pool *pgxpool.Pool, stats *databaseStats) (metric.Registration, error) {
+	meter := t.meter()
+
+	commits, err := meter.Int64ObservableCounter("postgresql.commits",
+		metric.WithDescription("The number of transactions committed in the database."),
+		metric.WithUnit("{transaction}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rollbacks, err := meter.Int64ObservableCounter("postgresql.rollbacks",
+		metric.WithDescription("The number of transactions rolled back in the database."),
+		metric.WithUnit("{transaction}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	hits, err := meter.Int64ObservableCounter("postgresql.blks_hit",
+		metric.WithDescription("The number of disk blocks found in the buffer cache."),
+		metric.WithUnit("{block}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	reads, err := meter.Int64ObservableCounter("postgresql.blks_read",
+		metric.WithDescription("The number of disk blocks read from disk."),
+		metric.WithUnit("{block}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	deadlocks, err := meter.Int64ObservableCounter("postgresql.deadlocks",
+		metric.WithDescription("The number of deadlocks detected in the database."),
+		metric.WithUnit("{deadlock}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	temp, err := meter.Int64ObservableCounter("postgresql.temp.io",
+		metric.WithDescription("The amount of data written to temporary files by queries."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBName(pool.Config().ConnConfig.Database),
+	}
+	attrs = stabilize(attrs)
+	attrs = append(attrs, t.poolName()...)
+	options := metric.WithAttributes(attrs...)
+
+	return meter.RegisterCallback(func(ctx context.Context, observer metric.Observer) error {
+		stats.mu.Lock()
+		defer stats.mu.Unlock()
+		// nothing to report before the first scrape
+		if !stats.ok {
+			return nil
+		}
+
+		observer.ObserveInt64(commits, stats.commits, options)
+		observer.ObserveInt64(rollbacks, stats.rollbacks, options)
+		observer.ObserveInt64(hits, stats.hits, options)
+		observer.ObserveInt64(reads, stats.reads, options)
+		observer.ObserveInt64(deadlocks, stats.deadlocks, options)
+		observer.ObserveInt64(temp, stats.temp, options)
+		// done!
+		return nil
+	}, commits, rollbacks, hits, reads, deadlocks, temp)
+}
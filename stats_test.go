@@ -0,0 +1,96 @@
+package pgxotel_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pgx-contrib/pgxotel"
+)
+
+func ExampleCollectDatabaseStats() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	config.ConnConfig.Tracer = &pgxotel.QueryTracer{
+		Name: "example-api",
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the pool
+	defer pool.Close()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	// stop the collector
+	defer cancel()
+
+	go pgxotel.CollectDatabaseStats(ctx, pool, time.Minute)
+}
+
+func ExampleStatementStats() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	stats := &pgxotel.StatementStats{}
+
+	config.ConnConfig.Tracer = pgxotel.NewQueryTracer("example-api",
+		pgxotel.WithStatementStats(stats),
+	)
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the pool
+	defer pool.Close()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	// stop the collector
+	defer cancel()
+
+	go stats.Collect(ctx, pool, time.Minute)
+}
+
+func ExampleStatementStats_fingerprint() {
+	// the statement normalized by pg_stat_statements
+	server := pgxotel.Fingerprint("SELECT name FROM customer WHERE id = $1 AND status IN ($2, $3) LIMIT $4")
+	// the statement issued by the application
+	client := pgxotel.Fingerprint("SELECT name FROM customer\nWHERE id = $1 AND status IN ('active') LIMIT 10")
+
+	fmt.Println(server == client)
+
+	// Output: true
+}
+
+func ExampleCollectReplicationLag() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	config.ConnConfig.Tracer = &pgxotel.QueryTracer{
+		Name: "example-api",
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the pool
+	defer pool.Close()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	// stop the collector
+	defer cancel()
+
+	go pgxotel.CollectReplicationLag(ctx, pool, 10*time.Second)
+}
@@ -0,0 +1,348 @@
+package pgxotel
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"slices"
+
+	pgx "github.com/jackc/pgx/v5"
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	attribute "go.opentelemetry.io/otel/attribute"
+	metric "go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// StatementCacheKey is the attribute key for the pgx cache of a statement
+	// (statement or describe).
+	StatementCacheKey = attribute.Key("pgx.statement_cache")
+	// PreparedStatementKey is the attribute key for the name of a prepared
+	// statement.
+	PreparedStatementKey = attribute.Key("db.postgresql.prepared_statement")
+)
+
+// statementCacheKey is the prefix of the connection custom data key of the cache
+// state.
+const statementCacheKey = "pgxotel.statement_cache"
+
+// statementCache approximates the state of the statement and description caches
+// of a connection: pgx does not expose them, but a cache miss prepares the
+// statement within the query.
+type statementCache struct {
+	// pending is the cache of the query in progress, if any
+	pending string
+	// sql is the statement of the query in progress
+	sql string
+	// exact reports whether the query in progress has arguments, so that it
+	// looks its statement up in the cache for sure
+	exact bool
+	// miss reports whether the query in progress prepared its statement
+	miss bool
+	// caches are the statements of the caches, by cache
+	caches map[string]*statementLRU
+}
+
+// statementLRU mirrors a least recently used cache of pgx: the most recently used
+// statements are at the front.
+type statementLRU struct {
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func newStatementLRU() *statementLRU {
+	return &statementLRU{order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// touch moves the statement to the front, and reports whether it was cached.
+func (c *statementLRU) touch(sql string) bool {
+	element, ok := c.entries[sql]
+	if ok {
+		c.order.MoveToFront(element)
+	}
+
+	return ok
+}
+
+// put adds the statement to the front, and reports whether the least recently
+// used statement was evicted to stay within the capacity.
+func (c *statementLRU) put(sql string, capacity int) bool {
+	if c.touch(sql) {
+		return false
+	}
+
+	evicted := false
+	if c.order.Len() >= capacity {
+		if back := c.order.Back(); back != nil {
+			delete(c.entries, c.order.Remove(back).(string))
+			evicted = true
+		}
+	}
+
+	c.entries[sql] = c.order.PushFront(sql)
+	// done!
+	return evicted
+}
+
+// remove removes the statement, and reports whether it was cached.
+func (c *statementLRU) remove(sql string) bool {
+	element, ok := c.entries[sql]
+	if ok {
+		c.order.Remove(element)
+		delete(c.entries, sql)
+	}
+
+	return ok
+}
+
+func (t *QueryTracer) statementCache(conn *pgx.Conn) *statementCache {
+	data := conn.PgConn().CustomData()
+
+	key := t.keys().statementCache
+	state, ok := data[key].(*statementCache)
+	if !ok {
+		state = &statementCache{caches: map[string]*statementLRU{}}
+		data[key] = state
+	}
+
+	return state
+}
+
+// statementKind returns the cache (statement or describe) a query with the
+// arguments looks its statement up in, if any.
+func (t *QueryTracer) statementKind(conn *pgx.Conn, args []any) string {
+	config := t.cache(conn).config
+
+	switch execMode(config, args) {
+	case pgx.QueryExecModeCacheStatement:
+		if config.StatementCacheCapacity > 0 {
+			return "statement"
+		}
+	case pgx.QueryExecModeCacheDescribe:
+		if config.DescriptionCacheCapacity > 0 {
+			return "describe"
+		}
+	}
+
+	return ""
+}
+
+// lookupStatement marks the start of a query that looks its statement up in a
+// cache, when the metrics are recorded or the query is traced (see recording).
+// Exec runs the queries without arguments with the simple protocol, which
+// bypasses the caches, while Query looks them up: the tracer cannot tell them
+// apart, so such a query is a miss when it prepares its statement and a hit when
+// its statement is known to be cached.
+func (t *QueryTracer) lookupStatement(conn *pgx.Conn, sql string, args []any, recording bool) {
+	if !t.Metrics && !recording {
+		return
+	}
+
+	if kind := t.statementKind(conn, args); kind != "" {
+		options, values := arguments(args)
+		// the rewriters (e.g. pgx.NamedArgs) produce the arguments
+		rewritten := slices.ContainsFunc(options, func(option any) bool {
+			_, ok := option.(pgx.QueryRewriter)
+			return ok
+		})
+
+		state := t.statementCache(conn)
+		state.pending = kind
+		state.sql = sql
+		state.exact = len(values) > 0 || rewritten
+		state.miss = false
+	}
+}
+
+// missStatement records that the query in progress prepares its statement.
+func (t *QueryTracer) missStatement(conn *pgx.Conn) {
+	if state, ok := conn.PgConn().CustomData()[t.keys().statementCache].(*statementCache); ok && state.pending != "" {
+		state.miss = true
+	}
+}
+
+// recordStatementCache records the cache lookup of the query that ended with err
+// and returns the cache of the query, if any. pgx invalidates the statement of a
+// failed query, which is deallocated before the next query. The caches are only
+// mirrored when the metrics are recorded.
+func (t *QueryTracer) recordStatementCache(ctx context.Context, conn *pgx.Conn, err error) string {
+	state, ok := conn.PgConn().CustomData()[t.keys().statementCache].(*statementCache)
+	if !ok || state.pending == "" {
+		return ""
+	}
+
+	kind := state.pending
+	state.pending = ""
+
+	if !t.Metrics {
+		// the query without arguments might not use the cache
+		if state.exact || state.miss {
+			return kind
+		}
+		return ""
+	}
+
+	cache, ok := state.caches[kind]
+	if !ok {
+		cache = newStatementLRU()
+		state.caches[kind] = cache
+	}
+
+	if !state.exact && !state.miss && !cache.touch(state.sql) {
+		// the query without arguments did not use the cache
+		return ""
+	}
+
+	config := t.cache(conn).config
+	capacity := config.StatementCacheCapacity
+	if kind == "describe" {
+		capacity = config.DescriptionCacheCapacity
+	}
+
+	delta := 0
+	evicted := false
+	switch {
+	case err != nil && !state.miss:
+		// the cached statement is invalidated
+		if cache.remove(state.sql) {
+			delta = -1
+		}
+	case err != nil:
+		// the statement prepared by the query is invalidated
+	case !state.miss:
+		// the statement is cached already, unless the query started before
+		// the tracer
+		if !cache.touch(state.sql) && !cache.put(state.sql, capacity) {
+			delta = 1
+		}
+	default:
+		// the least recently used statement is evicted when the cache is full
+		if evicted = cache.put(state.sql, capacity); !evicted {
+			delta = 1
+		}
+	}
+
+	metrics := t.instruments()
+	options := t.statementCacheOptions(conn, kind)
+	if state.miss {
+		metrics.misses.Add(ctx, 1, options)
+	} else {
+		metrics.hits.Add(ctx, 1, options)
+	}
+
+	if evicted {
+		metrics.evictions.Add(ctx, 1, options)
+	}
+
+	if err != nil {
+		metrics.invalid.Add(ctx, 1, options)
+	}
+
+	if delta != 0 {
+		metrics.cached.Add(ctx, int64(delta), options)
+	}
+
+	return kind
+}
+
+// statementCacheOptions returns the metric options of the cache (statement or
+// describe) of the connection.
+func (t *QueryTracer) statementCacheOptions(conn *pgx.Conn, kind string) metric.MeasurementOption {
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBName(t.cache(conn).config.Database),
+		StatementCacheKey.String(kind),
+	}
+	attrs = stabilize(attrs)
+	attrs = append(attrs, t.poolName()...)
+	return metric.WithAttributes(attrs...)
+}
+
+// clearStatementCache forgets the statements cached by the connection, whose
+// caches are recreated or closed.
+func (t *QueryTracer) clearStatementCache(ctx context.Context, conn *pgx.Conn) {
+	state, ok := conn.PgConn().CustomData()[t.keys().statementCache].(*statementCache)
+	if !ok {
+		return
+	}
+
+	if t.Metrics {
+		metrics := t.instruments()
+		for kind, cache := range state.caches {
+			if size := cache.order.Len(); size > 0 {
+				metrics.cached.Add(ctx, -int64(size), t.statementCacheOptions(conn, kind))
+			}
+		}
+	}
+
+	clear(state.caches)
+}
+
+// invalidated adds a StatementInvalidated event to the span of a query that
+// failed with err, whose statement pgx invalidates in its cache.
+func (t *QueryTracer) invalidated(span trace.Span, kind string, err error) {
+	if kind == "" || err == nil {
+		return
+	}
+
+	attrs := []attribute.KeyValue{}
+	attrs = append(attrs, StatementCacheKey.String(kind))
+
+	var perr *pgconn.PgError
+	if errors.As(err, &perr) {
+		attrs = append(attrs, PgErrorCodeKey.String(perr.Code))
+	}
+
+	t.event(span, "StatementInvalidated", attrs...)
+}
+
+// Deallocate releases the prepared statement of the connection, traced by a
+// Deallocate span.
+func (t *QueryTracer) Deallocate(ctx context.Context, conn *pgx.Conn, name string) error {
+	ctx, span := t.deallocate(ctx, conn, "Deallocate")
+
+	err := conn.Deallocate(ctx, name)
+	if span != nil {
+		attrs := []attribute.KeyValue{}
+		attrs = append(attrs, PreparedStatementKey.String(name))
+		// done!
+		t.stop(ctx, span, pgconn.CommandTag{}, err, attrs)
+	}
+
+	return err
+}
+
+// DeallocateAll releases all the prepared statements of the connection and resets
+// its caches, traced by a DeallocateAll span.
+func (t *QueryTracer) DeallocateAll(ctx context.Context, conn *pgx.Conn) error {
+	ctx, span := t.deallocate(ctx, conn, "DeallocateAll")
+
+	err := conn.DeallocateAll(ctx)
+	// the caches are recreated
+	t.clearStatementCache(ctx, conn)
+
+	if span != nil {
+		t.stop(ctx, span, pgconn.CommandTag{}, err, nil)
+	}
+
+	return err
+}
+
+// deallocate starts the span of a deallocation. The span is nil when the
+// deallocation is not traced.
+func (t *QueryTracer) deallocate(ctx context.Context, conn *pgx.Conn, name string) (context.Context, trace.Span) {
+	if !trace.SpanFromContext(ctx).IsRecording() || t.disabled(OperationPrepare) {
+		return ctx, nil
+	}
+
+	buffer := newBuffer()
+	attrs := buffer.attrs
+	attrs = append(attrs, t.cache(conn).attrs...)
+	attrs = append(attrs, t.instance(conn)...)
+	// prepare the span
+	ctx, span := t.start(ctx, name, attrs, t.SpanStartOptions[OperationPrepare]...)
+	buffer.free(attrs)
+	// done!
+	return ctx, span
+}
@@ -0,0 +1,123 @@
+package pgxotel_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pgx-contrib/pgxotel"
+	"github.com/pgx-contrib/pgxotel/internal/fakepg"
+	"github.com/pgx-contrib/pgxotel/pgxoteltest"
+	metric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// sums returns the sums of the int64 metrics of the statement cache.
+func sums(t *testing.T, reader metric.Reader) map[string]int64 {
+	t.Helper()
+
+	data := metricdata.ResourceMetrics{}
+	if err := reader.Collect(context.TODO(), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]int64{}
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok {
+				for _, point := range sum.DataPoints {
+					if kind, _ := point.Attributes.Value(pgxotel.StatementCacheKey); kind.AsString() == "statement" {
+						values[m.Name] += point.Value
+					}
+				}
+			}
+		}
+	}
+
+	return values
+}
+
+func TestQueryTracer_statementCache(t *testing.T) {
+	recorder := pgxoteltest.NewRecorder()
+	reader := metric.NewManualReader()
+	// the metrics are collected by the reader
+	provider := metric.NewMeterProvider(metric.WithReader(reader))
+
+	tracer := pgxotel.NewQueryTracer("example-api",
+		pgxotel.WithMetrics(),
+		pgxotel.WithMeterProvider(provider),
+		recorder.Option(),
+	)
+
+	server := &fakepg.Server{
+		Handler: func(sql string, args []string) fakepg.Result {
+			if slices.Contains(args, "fail") {
+				return fakepg.Result{Err: &pgconn.PgError{Code: "0A000", Message: "cached plan must not change result type"}}
+			}
+
+			return fakepg.Result{}
+		},
+	}
+
+	config, err := pgx.ParseConfig(fakepg.ConnString + "&statement_cache_capacity=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.DialFunc = server.Dial
+	config.Tracer = tracer
+
+	ctx, span := recorder.Start(context.TODO(), "test")
+
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// close the connection
+	defer conn.Close(context.TODO())
+
+	queries := []struct {
+		sql string
+		arg string
+	}{
+		{"UPDATE customer SET name = $1", "alice"},
+		{"UPDATE customer SET name = $1", "bob"},
+		{"UPDATE vendor SET name = $1", "alice"},
+		// the least recently used customer statement is evicted
+		{"UPDATE product SET name = $1", "alice"},
+		{"UPDATE customer SET name = $1", "carol"},
+		{"UPDATE product SET name = $1", "bob"},
+		// the failed statement is invalidated
+		{"UPDATE product SET name = $1", "fail"},
+	}
+
+	for _, query := range queries {
+		if _, err := conn.Exec(ctx, query.sql, query.arg); (err != nil) != (query.arg == "fail") {
+			t.Fatalf("unexpected error of %q: %v", query.sql, err)
+		}
+	}
+
+	span.End()
+
+	expected := map[string]int64{
+		"db.client.statement_cache.hits":          3,
+		"db.client.statement_cache.misses":        4,
+		"db.client.statement_cache.evictions":     2,
+		"db.client.statement_cache.invalidations": 1,
+		"db.client.statement_cache.size":          1,
+	}
+
+	values := sums(t, reader)
+	for name, value := range expected {
+		if values[name] != value {
+			t.Errorf("expected %d for %s, got %d", value, name, values[name])
+		}
+	}
+
+	failed := pgxoteltest.AssertQuerySpan(t, recorder.Spans(), pgxoteltest.WithError(&pgconn.PgError{Severity: "ERROR", Code: "0A000", Message: "cached plan must not change result type"}))
+	if !slices.Contains(failed.Events, "StatementInvalidated") {
+		t.Errorf("expected the StatementInvalidated event of the failed query, got %q", failed.Events)
+	}
+}
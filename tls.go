@@ -0,0 +1,48 @@
+package pgxotel
+
+import (
+	"crypto/tls"
+	"strings"
+
+	pgx "github.com/jackc/pgx/v5"
+	attribute "go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// TLSEstablishedKey is the attribute key that reports whether the connection
+	// is encrypted.
+	TLSEstablishedKey = attribute.Key("tls.established")
+	// TLSProtocolVersionKey is the attribute key for the TLS version (e.g. 1.3).
+	TLSProtocolVersionKey = attribute.Key("tls.protocol.version")
+	// TLSCipherKey is the attribute key for the negotiated cipher suite.
+	TLSCipherKey = attribute.Key("tls.cipher")
+	// TLSVerifiedKey is the attribute key that reports whether the certificate of
+	// the server was verified (sslmode verify-ca or verify-full).
+	TLSVerifiedKey = attribute.Key("db.postgresql.tls.verified")
+)
+
+// tls returns the TLS attributes of the connection.
+func (t *QueryTracer) tls(conn *pgx.Conn) []attribute.KeyValue {
+	if t.Minimal {
+		return nil
+	}
+
+	client, ok := conn.PgConn().Conn().(*tls.Conn)
+	if !ok {
+		return []attribute.KeyValue{TLSEstablishedKey.Bool(false)}
+	}
+
+	state := client.ConnectionState()
+	// verify-ca verifies the chain in VerifyPeerCertificate
+	verified := len(state.VerifiedChains) > 0
+	if config := t.cache(conn).config.TLSConfig; config != nil && config.VerifyPeerCertificate != nil {
+		verified = true
+	}
+
+	return []attribute.KeyValue{
+		TLSEstablishedKey.Bool(true),
+		TLSProtocolVersionKey.String(strings.TrimPrefix(tls.VersionName(state.Version), "TLS ")),
+		TLSCipherKey.String(tls.CipherSuiteName(state.CipherSuite)),
+		TLSVerifiedKey.Bool(verified),
+	}
+}
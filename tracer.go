@@ -1,19 +1,30 @@
 package pgxotel
 
 import (
-	"bufio"
 	"context"
 	"database/sql"
 	"errors"
-	"regexp"
+	"net"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
 
 	pgx "github.com/jackc/pgx/v5"
 	pgconn "github.com/jackc/pgx/v5/pgconn"
+	pgxpool "github.com/jackc/pgx/v5/pgxpool"
 	otel "go.opentelemetry.io/otel"
 	attribute "go.opentelemetry.io/otel/attribute"
 	codes "go.opentelemetry.io/otel/codes"
+	log "go.opentelemetry.io/otel/log"
+	metric "go.opentelemetry.io/otel/metric"
+	propagation "go.opentelemetry.io/otel/propagation"
 	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	stable "go.opentelemetry.io/otel/semconv/v1.26.0"
 	trace "go.opentelemetry.io/otel/trace"
 )
 
@@ -25,264 +36,1472 @@ var (
 	_ pgx.CopyFromTracer = (*QueryTracer)(nil)
 )
 
+const (
+	// QueryExecModeKey is the attribute key for the pgx query execution mode.
+	QueryExecModeKey = attribute.Key("pgx.query_exec_mode")
+	// HostsKey is the attribute key for the configured host list, including fallbacks.
+	HostsKey = attribute.Key("db.postgresql.hosts")
+	// HostKey is the attribute key for the configured host and port the connection
+	// landed on, one of the HostsKey.
+	HostKey = attribute.Key("db.postgresql.host")
+	// ServerRoleKey is the attribute key for the role (primary or standby) of the
+	// server the connection landed on.
+	ServerRoleKey = attribute.Key("db.postgresql.server_role")
+	// ReturnedRowsKey is the attribute key for the number of rows returned by a query.
+	ReturnedRowsKey = attribute.Key("db.response.returned_rows")
+	// SQLStateClassKey is the attribute key for the SQLSTATE class of an error.
+	SQLStateClassKey = attribute.Key("db.postgresql.sqlstate_class")
+	// CancelledKey is the attribute key that marks cancelled operations.
+	CancelledKey = attribute.Key("db.cancelled")
+	// PgErrorCodeKey is the attribute key for the SQLSTATE code of a PgError.
+	PgErrorCodeKey = attribute.Key("db.postgresql.error.code")
+	// PgErrorDetailKey is the attribute key for the detail of a PgError.
+	PgErrorDetailKey = attribute.Key("db.postgresql.error.detail")
+	// PgErrorHintKey is the attribute key for the hint of a PgError.
+	PgErrorHintKey = attribute.Key("db.postgresql.error.hint")
+	// PgErrorWhereKey is the attribute key for the context of a PgError.
+	PgErrorWhereKey = attribute.Key("db.postgresql.error.where")
+	// PgErrorPositionKey is the attribute key for the statement position of a PgError.
+	PgErrorPositionKey = attribute.Key("db.postgresql.error.position")
+	// ParameterCountKey is the attribute key for the number of bind parameters.
+	ParameterCountKey = attribute.Key("db.query.parameter_count")
+	// ParameterNullMaskKey is the attribute key for the null mask of the bind
+	// parameters, where 1 marks a null and 0 a non-null parameter.
+	ParameterNullMaskKey = attribute.Key("db.query.parameter_null_mask")
+	// BackendPIDKey is the attribute key for the process ID of the backend serving
+	// the connection.
+	BackendPIDKey = attribute.Key("db.postgresql.backend_pid")
+	// PrepareDurationKey is the attribute key for the time in seconds a query waited
+	// for the implicit prepare of its statement.
+	PrepareDurationKey = attribute.Key("db.postgresql.prepare.duration")
+	// ParameterNamesKey is the attribute key for the names of the named arguments.
+	ParameterNamesKey = attribute.Key("db.query.parameter_names")
+	// ParameterKeyPrefix is the prefix of the attribute keys for the values of the
+	// bind parameters, which are indexed by position or name.
+	ParameterKeyPrefix = "db.query.parameter."
+	// ParameterTypesKey is the attribute key for the Postgres type names of the bind
+	// parameters.
+	ParameterTypesKey = attribute.Key("db.query.parameter_types")
+	// PoolNameKey is the attribute key for the logical name of the pool.
+	PoolNameKey = stable.DBClientConnectionsPoolNameKey
+	// TenantKey is the attribute key for the tenant of an operation.
+	TenantKey = attribute.Key("tenant.id")
+	// RequestIDKey is the attribute key for the correlation ID of the request.
+	RequestIDKey = attribute.Key("request.id")
+	// DeadlockKey is the attribute key that marks operations aborted by a deadlock.
+	DeadlockKey = attribute.Key("db.deadlock")
+	// SerializationFailureKey is the attribute key that marks operations aborted by a
+	// serialization failure.
+	SerializationFailureKey = attribute.Key("db.serialization_failure")
+	// ErrorTypeKey is the attribute key for the class of an error.
+	ErrorTypeKey = attribute.Key("error.type")
+	// StatementCountKey is the attribute key for the number of statements of a
+	// multi-statement query.
+	StatementCountKey = attribute.Key("db.postgresql.statement.count")
+	// StatementIndexKey is the attribute key for the index of a statement within a
+	// multi-statement query.
+	StatementIndexKey = attribute.Key("db.postgresql.statement.index")
+)
+
+// RuntimeParamKeyPrefix is the prefix of the attribute keys for run-time parameters.
+const RuntimeParamKeyPrefix = "db.postgresql.runtime_param."
+
+// CancellationMode controls how context.Canceled and context.DeadlineExceeded
+// errors are recorded.
+type CancellationMode int
+
+const (
+	// CancellationError records cancellations as errors.
+	CancellationError CancellationMode = iota
+	// CancellationAttribute records cancellations with a db.cancelled attribute
+	// without setting the Error status.
+	CancellationAttribute
+	// CancellationIgnore does not record cancellations at all.
+	CancellationIgnore
+)
+
+// OperationType identifies a type of traced operation.
+type OperationType string
+
+const (
+	// OperationConnect is the type of Connect spans.
+	OperationConnect OperationType = "connect"
+	// OperationPrepare is the type of Prepare spans.
+	OperationPrepare OperationType = "prepare"
+	// OperationQuery is the type of query spans.
+	OperationQuery OperationType = "query"
+	// OperationBatch is the type of BatchStart spans.
+	OperationBatch OperationType = "batch"
+	// OperationBatchQuery is the type of the spans of the queries of a batch.
+	OperationBatchQuery OperationType = "batch_query"
+	// OperationCopyFrom is the type of Copy spans.
+	OperationCopyFrom OperationType = "copy_from"
+	// OperationTransaction is the type of Transaction and Savepoint spans.
+	OperationTransaction OperationType = "transaction"
+	// OperationLargeObject is the type of LargeObject spans.
+	OperationLargeObject OperationType = "large_object"
+	// OperationClose is the type of Close spans.
+	OperationClose OperationType = "close"
+)
+
+// StatusMapper maps the outcome of an operation to a span status and description.
+type StatusMapper func(err error, tag pgconn.CommandTag) (codes.Code, string)
+
 // QueryTracer is a wrapper around the pgx tracer interfaces which instrument queries.
 type QueryTracer struct {
 	// Name of the tracer
 	Name string
 	// Options to provide to the tracer
 	Options []trace.TracerOption
+	// TracerProvider provides the tracer (defaults to the global provider)
+	TracerProvider trace.TracerProvider
+	// Version is the instrumentation version of the tracer (defaults to the
+	// version of the module).
+	Version string
+	// SchemaURL is the schema URL of the tracer (defaults to the semantic
+	// conventions in use).
+	SchemaURL string
+	// SpanStartOptions are provided to the spans of the given operation type, in
+	// addition to the default options.
+	SpanStartOptions map[OperationType][]trace.SpanStartOption
+	// DeferredSpans creates the spans when the operations end, with the recorded
+	// start time, attributes and events. The operation is kept along with the
+	// spans kept within it. The trace context propagated to the server and the
+	// links to the operation are the ones of its parent.
+	DeferredSpans bool
+	// OnEnd is called when a traced operation ends, with the span of the operation
+	// if it is recorded.
+	OnEnd func(ctx context.Context, op Operation, err error, span trace.Span)
+	// ErrorsOnly emits only the spans of the operations that fail or take longer
+	// than the SlowThreshold. It implies DeferredSpans.
+	ErrorsOnly bool
+	// SlowThreshold is the duration after which the spans of successful
+	// operations are emitted in ErrorsOnly mode. Disabled when zero.
+	SlowThreshold time.Duration
+	// LockDiagnostics is the pool used to diagnose the locks of the queries that run
+	// longer than the LockThreshold. The blocking backends are recorded as LockWait
+	// events of the query spans.
+	LockDiagnostics *pgxpool.Pool
+	// LockThreshold is the duration after which the locks of running queries are
+	// diagnosed (see LockDiagnostics). Disabled when zero.
+	LockThreshold time.Duration
+	// MinimumSpanDuration drops the spans of the successful operations that are
+	// faster than the duration. It implies DeferredSpans.
+	MinimumSpanDuration time.Duration
+	// DisableSpans disables all the spans, so that only the metrics are recorded
+	// (see Metrics).
+	DisableSpans bool
+	// RootSpans creates the spans of the work done in the background, the Close
+	// spans of BeforeClose and the Probe spans of Monitor, as root spans. They are
+	// only created under a recording span otherwise.
+	RootSpans bool
+	// DisabledOperations are the operation types that are not traced. The spans
+	// nested in a disabled operation (e.g. the queries of a batch) are not traced
+	// either. The metrics are recorded regardless.
+	DisabledOperations []OperationType
+	// Attributes are recorded on every span (e.g. region, shard or tier).
+	Attributes []attribute.KeyValue
+	// RuntimeParams are the names of the ConnConfig.RuntimeParams (e.g. search_path)
+	// recorded on every span.
+	RuntimeParams []string
+	// InstanceID identifies the database instance or cluster on every span.
+	InstanceID string
+	// DiscoverInstanceID discovers the instance ID from the cluster_name setting or
+	// the system identifier when a connection is established (see AfterConnect).
+	DiscoverInstanceID bool
+	// DiscoverServerFacts queries the server_version, is_superuser and TimeZone
+	// settings once when a connection is established (see AfterConnect) and
+	// records them on the spans of the connection.
+	DiscoverServerFacts bool
+	// ServerVersion records the version of the server, which is reported by the
+	// server when a connection is established, on every span instead of only the
+	// Connect spans.
+	ServerVersion bool
+	// Pooler names the connection pooler (e.g. pgbouncer or pgcat) between the
+	// application and the server, which is recorded on every span.
+	Pooler string
+	// PoolMode is the pool mode (session, transaction or statement) of the Pooler.
+	PoolMode string
+	// DetectPooler detects the Pooler from the host name and the port of the
+	// connections when it is not configured. PgBouncer and pgcat listen on port
+	// 6432 by default.
+	DetectPooler bool
+	// TraceIDParameter is the run-time parameter (e.g. application_name) that
+	// carries the current trace ID. Propagation is disabled when empty.
+	TraceIDParameter string
+	// TraceIDInterval is the minimum interval between two updates of the
+	// TraceIDParameter on the same connection. Meanwhile the connection keeps the
+	// trace ID of the previous update, so that the pg_stat_activity rows of the
+	// other traces carry a stale trace ID.
+	TraceIDInterval time.Duration
+	// TraceParentParameter is the run-time parameter (e.g. otel.traceparent) that
+	// carries the W3C traceparent within transactions started by BeginTx.
+	TraceParentParameter string
+	// Metrics enables the metric instruments of the tracer.
+	Metrics bool
+	// MeterProvider provides the meter of the metric instruments (defaults to the
+	// global provider)
+	MeterProvider metric.MeterProvider
+	// LoggerProvider provides the logger of the log records emitted by Logger
+	// (defaults to the global provider)
+	LoggerProvider log.LoggerProvider
+	// OkStatus sets the status of successful spans to Ok instead of leaving it Unset.
+	OkStatus bool
+	// Cancellation controls how context cancellations are recorded.
+	Cancellation CancellationMode
+	// BenignErrors are errors (e.g. pgx.ErrTxClosed) that end spans without the
+	// Error status, in addition to pgx.ErrNoRows and sql.ErrNoRows.
+	BenignErrors []error
+	// BenignCodes are SQLSTATE codes (e.g. 42P05) that end spans without the
+	// Error status.
+	BenignCodes []string
+	// StatusMapper, when set, determines the status of every span. It takes
+	// precedence over OkStatus, Cancellation, BenignErrors and BenignCodes.
+	StatusMapper StatusMapper
+	// PgErrorEvent records the Detail, Hint, Where and Position of a
+	// *pgconn.PgError as a span event.
+	PgErrorEvent bool
+	// PgErrorRedactor rewrites the detail, hint and where fields of a PgError
+	// before they are recorded, since they can contain row data.
+	PgErrorRedactor func(field, value string) string
+	// ParameterNullMask records which bind parameters are null.
+	ParameterNullMask bool
+	// ParameterNames records the names (not the values) of pgx.NamedArgs and
+	// pgx.StrictNamedArgs.
+	ParameterNames bool
+	// ParameterTypes records the Postgres type names (not the values) the bind
+	// parameters are encoded as, in the pgx.QueryExecModeExec and
+	// pgx.QueryExecModeSimpleProtocol modes only: the other modes encode the
+	// parameters as the types described by the server, which pgx does not expose.
+	// The prepared statements are encoded as their described types as well.
+	ParameterTypes bool
+	// ParameterValues records the values of the bind parameters. It only takes
+	// effect in binaries built with the pgxotel_debug build tag.
+	ParameterValues bool
+	// UserRedactor rewrites the db.user attribute (e.g. with a hash or placeholder).
+	UserRedactor func(user string) string
+	// Sanitizer rewrites the statements recorded as db.statement (defaults to
+	// CommentSanitizer).
+	Sanitizer StatementSanitizer
+	// SpanNamePrefix is prepended to the names of the spans.
+	SpanNamePrefix string
+	// AttributeMapper rewrites the attributes before they are set on a span or on
+	// its events. It can rename keys, drop attributes or rewrite values, and may
+	// reuse the slice.
+	AttributeMapper func(attrs []attribute.KeyValue) []attribute.KeyValue
+	// OmitConnectionString omits the db.connection_string attribute.
+	OmitConnectionString bool
+	// Minimal records only the span name, duration, status and db.system.
+	Minimal bool
+	// CompactBatch records the queries of a batch as BatchQuery events of the
+	// batch span instead of child spans.
+	CompactBatch bool
+	// BatchRootLink links the spans of the batch queries to the root span of the
+	// request (see ContextWithRootSpan), for the backends that cut traces at batch
+	// boundaries.
+	BatchRootLink bool
+	// ConnectPhases records the phases of a connection attempt as events of the
+	// Connect span (see InstrumentConnect).
+	ConnectPhases bool
+	// CallerAttributes records the call site of the application (code.function,
+	// code.filepath and code.lineno) on query spans.
+	CallerAttributes bool
+	// Fingerprint records the fingerprint of the statements of query spans (see
+	// FingerprintKey).
+	Fingerprint bool
+	// StatementStats records the pg_stat_statements statistics of the statements on
+	// query spans.
+	StatementStats *StatementStats
+	// MaxEvents is the maximum number of events recorded per span of the tracer
+	// (unlimited when zero). The events of the other spans (e.g. the Log events on
+	// the spans of the application) are not limited.
+	MaxEvents int
+	// MaxAttributeBytes is the maximum length of the string attribute values (e.g.
+	// statements, connection strings and notices), error messages and status
+	// descriptions, which are truncated beyond it and end with TruncationMarker
+	// (unlimited when zero).
+	MaxAttributeBytes int
+	// OtelpgxCompatible records the span names and attribute keys of otelpgx
+	// (github.com/exaring/otelpgx), so that the dashboards and alerts built on
+	// them keep working.
+	OtelpgxCompatible bool
+	// PoolName is the logical name of the pool (e.g. read-replica) recorded on
+	// every span and metric, which tells the pools of an application apart.
+	PoolName string
+	// CommentPropagator injects the keys of the SQL comments appended by Commenter
+	// (defaults to the W3C trace context). Composite propagators, B3 or CommentTags
+	// select other comment schemas.
+	CommentPropagator propagation.TextMapPropagator
+	// CommentKeys restricts the keys of the SQL comments to the given ones (all
+	// the injected keys when empty).
+	CommentKeys []string
+	// Clock tells the time of the durations and schedules the timers of the tracer
+	// (defaults to the system clock).
+	Clock Clock
+	// TenantFunc returns the tenant of the operation, which is recorded on every
+	// span and metric.
+	TenantFunc func(ctx context.Context) string
+	// RequestIDFunc returns the correlation ID of the request, which is recorded on
+	// every span.
+	RequestIDFunc func(ctx context.Context) string
+
+	once         sync.Once
+	metrics      *instruments
+	setup        sync.Once
+	options      []trace.TracerOption
+	statements   memo[attribute.KeyValue]
+	tables       memo[string]
+	hints        memo[*hint]
+	fingerprints memo[string]
+	connections  memo[string]
+	events       sync.Map
+	keysOnce     sync.Once
+	dataKeys     dataKeys
 }
 
 // TraceConnectStart implements pgx.ConnectTracer.
 func (t *QueryTracer) TraceConnectStart(ctx context.Context, data pgx.TraceConnectStartData) context.Context {
-	if !trace.SpanFromContext(ctx).IsRecording() {
+	ctx = t.begin(ctx, OperationConnect, data.ConnConfig.Database, "")
+	if t.DisableSpans || !trace.SpanFromContext(ctx).IsRecording() {
 		return ctx
 	}
 
+	if t.disabled(OperationConnect) {
+		return untraced(ctx)
+	}
+
 	// attributes
-	attrs := []attribute.KeyValue{}
+	buffer := newBuffer()
+	attrs := buffer.attrs
 	attrs = append(attrs, t.config(data.ConnConfig)...)
+	attrs = append(attrs, t.hosts(data.ConnConfig)...)
+	attrs = append(attrs, t.instance(nil)...)
 	// prepare the span
-	ctx, span := t.start(ctx, "Connect", attrs)
-	span.AddEvent("ConnectStart")
+	ctx, span := t.start(ctx, t.spanName(OperationConnect, "Connect"), attrs, t.SpanStartOptions[OperationConnect]...)
+	buffer.free(attrs)
+	t.event(span, "ConnectStart")
 	// done!
 	return ctx
 }
 
 // TraceConnectEnd implements pgx.ConnectTracer.
 func (t *QueryTracer) TraceConnectEnd(ctx context.Context, data pgx.TraceConnectEndData) {
+	t.recordConnect(ctx, data.Err)
+	if data.Conn != nil {
+		t.opened(data.Conn)
+	}
+
 	span := trace.SpanFromContext(ctx)
-	span.AddEvent("ConnectEnd")
+	if t.DisableSpans || !span.IsRecording() {
+		t.finish(ctx, nil, pgconn.CommandTag{}, data.Err)
+		return
+	}
 
-	attrs := []attribute.KeyValue{}
+	t.event(span, "ConnectEnd")
+
+	buffer := newBuffer()
+	attrs := buffer.attrs
+	if data.Conn != nil {
+		attrs = append(attrs, t.peer(data.Conn.PgConn())...)
+		attrs = append(attrs, t.tls(data.Conn)...)
+		attrs = append(attrs, t.server(data.Conn)...)
+		attrs = append(attrs, t.serverVersion(data.Conn.PgConn())...)
+	}
 	// done
-	t.stop(span, data.Err, attrs)
+	t.stop(ctx, span, pgconn.CommandTag{}, data.Err, attrs)
+	buffer.free(attrs)
 }
 
 // TracePrepareStart implements pgx.PrepareTracer.
 func (t *QueryTracer) TracePrepareStart(ctx context.Context, conn *pgx.Conn, data pgx.TracePrepareStartData) context.Context {
-	if !trace.SpanFromContext(ctx).IsRecording() {
+	ctx = t.begin(ctx, OperationPrepare, t.cache(conn).config.Database, data.SQL)
+	t.missStatement(conn)
+	if t.DisableSpans || !trace.SpanFromContext(ctx).IsRecording() {
 		return ctx
 	}
 
-	attrs := []attribute.KeyValue{}
-	attrs = append(attrs, t.config(conn.Config())...)
-	attrs = append(attrs, t.statement(data.SQL))
+	// the query span records the duration of an implicit prepare
+	if parent := trace.SpanFromContext(ctx); conn.PgConn().CustomData()[spanKey] == parent {
+		ctx = context.WithValue(ctx, implicitKey{}, &implicit{span: parent, start: t.clock().Now()})
+	}
+
+	if t.disabled(OperationPrepare) {
+		return untraced(ctx)
+	}
+
+	buffer := newBuffer()
+	attrs := buffer.attrs
+	attrs = append(attrs, t.cache(conn).attrs...)
+	attrs = append(attrs, t.instance(conn)...)
+	attrs = append(attrs, t.keyword(data.SQL)...)
+	if t.OtelpgxCompatible && data.Name != "" {
+		attrs = append(attrs, PrepareNameKey.String(data.Name))
+	}
 
 	// prepare the context
-	ctx, span := t.start(ctx, data.SQL, attrs)
-	span.AddEvent("PrepareStart")
+	ctx, span := t.start(ctx, t.spanName(OperationPrepare, data.SQL), attrs, t.SpanStartOptions[OperationPrepare]...)
+	buffer.free(attrs)
+	t.event(span, "PrepareStart")
+	// the statement is only sanitized for sampled spans
+	if span.IsRecording() && !t.Minimal {
+		t.annotate(span, t.statement(data.SQL))
+	}
+	t.attach(conn, span)
 	// done!
 	return ctx
 }
 
 // TracePrepareEnd implements pgx.PrepareTracer.
 func (t *QueryTracer) TracePrepareEnd(ctx context.Context, conn *pgx.Conn, data pgx.TracePrepareEndData) {
+	p, _ := ctx.Value(implicitKey{}).(*implicit)
+	if p != nil {
+		t.annotate(p.span, PrepareDurationKey.Float64(t.since(p.start).Seconds()))
+	}
+
 	span := trace.SpanFromContext(ctx)
-	span.AddEvent("PrepareEnd")
+	if t.DisableSpans || !span.IsRecording() {
+		t.finish(ctx, nil, pgconn.CommandTag{}, data.Err)
+		return
+	}
 
-	attrs := []attribute.KeyValue{}
+	t.event(span, "PrepareEnd")
+	// the query continues after an implicit prepare
+	if p != nil {
+		t.attach(conn, p.span)
+	} else {
+		t.detach(conn)
+	}
+
+	buffer := newBuffer()
+	attrs := buffer.attrs
 	// done
-	t.stop(span, data.Err, attrs)
+	t.stop(ctx, span, pgconn.CommandTag{}, data.Err, attrs)
+	buffer.free(attrs)
+}
+
+// implicit tracks a prepare issued by pgx while executing a query (e.g. on a
+// statement cache miss).
+type implicit struct {
+	// span is the query span
+	span trace.Span
+	// start is the time the prepare started
+	start time.Time
 }
 
+type implicitKey struct{}
+
 // TraceQueryStart implements pgx.QueryTracer.
 func (t *QueryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
-	if !trace.SpanFromContext(ctx).IsRecording() {
+	ctx = t.begin(ctx, OperationQuery, t.cache(conn).config.Database, data.SQL)
+	t.recordActive(ctx, 1)
+	recording := !t.DisableSpans && trace.SpanFromContext(ctx).IsRecording()
+	t.lookupStatement(conn, data.SQL, data.Args, recording)
+	t.countStatement(conn)
+	if !recording {
 		return ctx
 	}
 
-	attrs := []attribute.KeyValue{}
-	attrs = append(attrs, t.config(conn.Config())...)
-	attrs = append(attrs, t.statement(data.SQL))
+	if t.disabled(OperationQuery) {
+		return untraced(ctx)
+	}
+
+	buffer := newBuffer()
+	attrs := buffer.attrs
+	attrs = append(attrs, t.cache(conn).attrs...)
+	attrs = append(attrs, t.instance(conn)...)
+	attrs = append(attrs, t.mode(t.cache(conn).config, data.Args)...)
+	attrs = append(attrs, t.parameters(data.Args)...)
+	attrs = append(attrs, t.types(conn, data.Args)...)
+	attrs = append(attrs, t.values(data.Args)...)
+	attrs = append(attrs, t.table(data.SQL)...)
+	attrs = append(attrs, t.fingerprint(data.SQL)...)
+	attrs = append(attrs, t.statementStats(data.SQL)...)
+	attrs = append(attrs, t.caller()...)
+	attrs = append(attrs, t.keyword(data.SQL)...)
 	// prepare the context
-	ctx, span := t.start(ctx, data.SQL, attrs)
-	span.AddEvent("QueryStart")
+	ctx, span := t.start(ctx, t.spanName(OperationQuery, data.SQL), attrs, t.SpanStartOptions[OperationQuery]...)
+	buffer.free(attrs)
+	t.event(span, "QueryStart")
+	// the statement is only sanitized for sampled spans
+	if span.IsRecording() && !t.Minimal {
+		t.annotate(span, t.statement(data.SQL))
+		// the simple protocol runs multi-statement queries without arguments
+		if len(data.Args) == 0 {
+			t.script(span, data.SQL)
+		}
+	}
+	t.attach(conn, span)
+	t.watchLocks(ctx, conn, span)
+	// register the span for the rows returned by Query
+	if f := fetchFrom(ctx); f != nil && f.span == nil {
+		f.start = t.clock().Now()
+		f.span = span
+		f.tracer = t
+	}
 	// done!
 	return ctx
 }
 
 // TraceQueryEnd implements pgx.QueryTracer.
 func (t *QueryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	t.recordActive(ctx, -1)
+	kind := t.recordStatementCache(ctx, conn, data.Err)
+	if data.CommandTag.Select() {
+		// record the metric
+		t.recordRows(ctx, conn, data.CommandTag.RowsAffected())
+	}
+
 	span := trace.SpanFromContext(ctx)
-	span.AddEvent("QueryEnd")
+	if t.DisableSpans || !span.IsRecording() {
+		t.finish(ctx, nil, data.CommandTag, data.Err)
+		return
+	}
 
-	attrs := []attribute.KeyValue{}
+	t.unwatchLocks(conn, span)
+	t.invalidated(span, kind, data.Err)
+	t.event(span, "QueryEnd")
+	t.detach(conn)
+
+	buffer := newBuffer()
+	attrs := buffer.attrs
+	// the time to first row is only recorded once a row was read
+	if f := fetchFrom(ctx); f != nil && f.span == span {
+		// the fetch span ends first
+		f.end(data.CommandTag, data.Err)
+	}
+	if data.CommandTag.Select() {
+		attrs = append(attrs, ReturnedRowsKey.Int64(data.CommandTag.RowsAffected()))
+	}
+	attrs = append(attrs, t.affected(data.CommandTag, data.Err)...)
 	// done
-	t.stop(span, data.Err, attrs)
+	t.stop(ctx, span, data.CommandTag, data.Err, attrs)
+	buffer.free(attrs)
 }
 
 // TraceCopyFromStart implements pgx.CopyFromTracer.
 func (t *QueryTracer) TraceCopyFromStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
-	if !trace.SpanFromContext(ctx).IsRecording() {
+	ctx = t.begin(ctx, OperationCopyFrom, t.cache(conn).config.Database, "")
+	if op := operationFrom(ctx); op != nil {
+		op.table = data.TableName.Sanitize()
+	}
+	t.recordActive(ctx, 1)
+	t.countStatement(conn)
+
+	if t.DisableSpans || !trace.SpanFromContext(ctx).IsRecording() {
 		return ctx
 	}
 
+	if t.disabled(OperationCopyFrom) {
+		return untraced(ctx)
+	}
+
 	// attributes
-	attrs := []attribute.KeyValue{}
-	attrs = append(attrs, t.config(conn.Config())...)
+	buffer := newBuffer()
+	attrs := buffer.attrs
+	attrs = append(attrs, t.cache(conn).attrs...)
+	attrs = append(attrs, t.instance(conn)...)
 	attrs = append(attrs, t.collection(data.TableName))
+	name := "Copy"
+	if t.OtelpgxCompatible {
+		name = "copy_from " + data.TableName.Sanitize()
+	}
 	// prepare the context
-	ctx, span := t.start(ctx, "Copy", attrs)
-	span.AddEvent("CopyFromStart")
+	ctx, span := t.start(ctx, name, attrs, t.SpanStartOptions[OperationCopyFrom]...)
+	buffer.free(attrs)
+	t.event(span, "CopyFromStart")
+	t.attach(conn, span)
+	// register the span for the source returned by CopyFromSource
+	if p := progressFrom(ctx); p != nil && p.span == nil {
+		p.span = span
+		p.tracer = t
+	}
 	// done!
 	return ctx
 }
 
 // TraceCopyFromEnd implements pgx.CopyFromTracer.
 func (t *QueryTracer) TraceCopyFromEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	t.recordActive(ctx, -1)
+	// record the metrics
+	t.recordCopy(ctx, data.CommandTag.RowsAffected())
+
 	span := trace.SpanFromContext(ctx)
-	span.AddEvent("CopyFromEnd")
+	if t.DisableSpans || !span.IsRecording() {
+		t.finish(ctx, nil, data.CommandTag, data.Err)
+		return
+	}
 
-	attrs := []attribute.KeyValue{}
+	t.event(span, "CopyFromEnd")
+	t.detach(conn)
+
+	buffer := newBuffer()
+	attrs := buffer.attrs
 	attrs = append(attrs, t.command(data.CommandTag))
+	if p := progressFrom(ctx); p != nil && p.span == span {
+		attrs = append(attrs, CopyRowsKey.Int64(p.rows))
+	}
+	attrs = append(attrs, t.affected(data.CommandTag, data.Err)...)
 	// done!
-	t.stop(span, data.Err, attrs)
+	t.stop(ctx, span, data.CommandTag, data.Err, attrs)
+	buffer.free(attrs)
 }
 
 // TraceBatchStart implements pgx.BatchTracer.
 func (t *QueryTracer) TraceBatchStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
-	if !trace.SpanFromContext(ctx).IsRecording() {
+	ctx = t.begin(ctx, OperationBatch, t.cache(conn).config.Database, "")
+	t.recordActive(ctx, 1)
+	if t.DisableSpans || !trace.SpanFromContext(ctx).IsRecording() {
 		return ctx
 	}
 
-	attrs := []attribute.KeyValue{}
-	attrs = append(attrs, t.config(conn.Config())...)
+	if t.disabled(OperationBatch) {
+		return untraced(ctx)
+	}
+
+	buffer := newBuffer()
+	attrs := buffer.attrs
+	attrs = append(attrs, t.cache(conn).attrs...)
+	attrs = append(attrs, t.instance(conn)...)
 	// prepare the context
-	ctx, _ = t.start(ctx, "BatchStart", attrs)
+	ctx, span := t.start(ctx, t.spanName(OperationBatch, "BatchStart"), attrs, t.SpanStartOptions[OperationBatch]...)
+	buffer.free(attrs)
+	t.attach(conn, span)
 	// done!
 	return ctx
 }
 
 // TraceBatchQuery implements pgx.BatchTracer.
 func (t *QueryTracer) TraceBatchQuery(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchQueryData) {
-	attrs := []attribute.KeyValue{}
-	attrs = append(attrs, t.config(conn.Config())...)
+	link, linked := enqueued(ctx)
+	ctx = t.begin(ctx, OperationBatchQuery, t.cache(conn).config.Database, data.SQL)
+	t.countStatement(conn)
+	if data.CommandTag.Select() {
+		// record the metric
+		t.recordRows(ctx, conn, data.CommandTag.RowsAffected())
+	}
+
+	if !trace.SpanFromContext(ctx).IsRecording() || t.disabled(OperationBatchQuery) {
+		t.finish(ctx, nil, data.CommandTag, data.Err)
+		return
+	}
+
+	if t.CompactBatch {
+		t.finish(ctx, nil, data.CommandTag, data.Err)
+		// the query is recorded as an event of the batch span
+		t.compact(trace.SpanFromContext(ctx), data)
+		return
+	}
+
+	buffer := newBuffer()
+	attrs := buffer.attrs
+	attrs = append(attrs, t.cache(conn).attrs...)
+	attrs = append(attrs, t.instance(conn)...)
 	attrs = append(attrs, t.command(data.CommandTag))
-	attrs = append(attrs, t.statement(data.SQL))
+	attrs = append(attrs, t.mode(t.cache(conn).config, data.Args)...)
+	attrs = append(attrs, t.parameters(data.Args)...)
+	attrs = append(attrs, t.types(conn, data.Args)...)
+	attrs = append(attrs, t.values(data.Args)...)
+	attrs = append(attrs, t.table(data.SQL)...)
+	attrs = append(attrs, t.fingerprint(data.SQL)...)
+	attrs = append(attrs, t.keyword(data.SQL)...)
+	if data.CommandTag.Select() {
+		attrs = append(attrs, ReturnedRowsKey.Int64(data.CommandTag.RowsAffected()))
+	}
+	attrs = append(attrs, t.affected(data.CommandTag, data.Err)...)
+
+	var links []trace.Link
+	if linked {
+		links = append(links, link)
+	}
+	if root, ok := rootFrom(ctx); ok && t.BatchRootLink {
+		links = append(links, root)
+	}
+
+	options := t.SpanStartOptions[OperationBatchQuery]
+	if len(links) > 0 {
+		options = append([]trace.SpanStartOption{trace.WithLinks(links...)}, options...)
+	}
 
 	// prepare the context
-	_, span := t.start(ctx, data.SQL, attrs)
-	span.AddEvent("BatchQuery")
+	ctx, span := t.start(ctx, t.spanName(OperationBatchQuery, data.SQL), attrs, options...)
+	t.event(span, "BatchQuery")
+	if data.Err != nil {
+		t.invalidated(span, t.statementKind(conn, data.Args), data.Err)
+	}
+	// the statement is only sanitized for sampled spans
+	if span.IsRecording() && !t.Minimal {
+		attrs = append(attrs, t.statement(data.SQL))
+	}
 	// done!
-	t.stop(span, data.Err, attrs)
+	t.stop(ctx, span, data.CommandTag, data.Err, attrs)
+	buffer.free(attrs)
+}
+
+// compact records the query of a batch as a BatchQuery event of the batch span.
+func (t *QueryTracer) compact(span trace.Span, data pgx.TraceBatchQueryData) {
+	if t.Minimal {
+		return
+	}
+
+	attrs := []attribute.KeyValue{}
+	attrs = append(attrs, t.statement(data.SQL))
+	attrs = append(attrs, t.command(data.CommandTag))
+	if data.CommandTag.Select() {
+		attrs = append(attrs, ReturnedRowsKey.Int64(data.CommandTag.RowsAffected()))
+	}
+	if data.Err != nil {
+		attrs = append(attrs, semconv.ExceptionMessage(data.Err.Error()))
+	}
+
+	t.event(span, "BatchQuery", attrs...)
+}
+
+// script adds a Statement event per statement of a multi-statement query.
+func (t *QueryTracer) script(span trace.Span, query string) {
+	if strings.IndexByte(query, ';') < 0 {
+		return
+	}
+
+	statements := split(query)
+	if len(statements) < 2 {
+		return
+	}
+
+	t.annotate(span, StatementCountKey.Int(len(statements)))
+
+	for index, statement := range statements {
+		attrs := []attribute.KeyValue{}
+		attrs = append(attrs, StatementIndexKey.Int(index))
+		attrs = append(attrs, semconv.DBOperation(OperationFromSQL(statement)))
+		attrs = append(attrs, t.sanitize(statement))
+		attrs = stabilize(attrs)
+
+		t.event(span, "Statement", attrs...)
+	}
 }
 
 // TraceBatchEnd implements pgx.BatchTracer.
 func (t *QueryTracer) TraceBatchEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchEndData) {
+	t.recordActive(ctx, -1)
+
 	span := trace.SpanFromContext(ctx)
-	span.AddEvent("BatchEnd")
+	if t.DisableSpans || !span.IsRecording() {
+		t.finish(ctx, nil, pgconn.CommandTag{}, data.Err)
+		return
+	}
 
-	attrs := []attribute.KeyValue{}
+	t.event(span, "BatchEnd")
+	t.detach(conn)
+
+	buffer := newBuffer()
+	attrs := buffer.attrs
 	// done
-	t.stop(span, data.Err, attrs)
+	t.stop(ctx, span, pgconn.CommandTag{}, data.Err, attrs)
+	buffer.free(attrs)
 }
 
 func (q *QueryTracer) tracer() trace.Tracer {
+	provider := q.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
 	// get the tracer
-	return otel.GetTracerProvider().Tracer(q.Name, q.Options...)
+	return provider.Tracer(q.Name, q.tracerOptions()...)
 }
 
-var pattern = regexp.MustCompile(`^--\s+name:\s+(\w+)`)
+// client is the span kind of the database operations.
+var client = trace.WithSpanKind(trace.SpanKindClient)
 
-func (q *QueryTracer) start(ctx context.Context, name string, attrs []attribute.KeyValue) (context.Context, trace.Span) {
-	if match := pattern.FindStringSubmatch(name); len(match) == 2 {
-		name = match[1]
+// untraced returns a context in which internal statements do not produce spans.
+func untraced(ctx context.Context) context.Context {
+	return trace.ContextWithSpanContext(ctx, trace.SpanContextFromContext(ctx))
+}
+
+// disabled reports whether the spans of the operation type are disabled.
+func (t *QueryTracer) disabled(operation OperationType) bool {
+	if t.DisableSpans {
+		return true
+	}
+
+	return len(t.DisabledOperations) > 0 && slices.Contains(t.DisabledOperations, operation)
+}
+
+// event adds the event to the span, unless the tracer is minimal or the span
+// reached MaxEvents.
+func (t *QueryTracer) event(span trace.Span, name string, attrs ...attribute.KeyValue) {
+	if t.Minimal || !t.allow(span) {
+		return
 	}
 
-	options := []trace.SpanStartOption{
-		trace.WithSpanKind(trace.SpanKindClient),
-		trace.WithAttributes(attrs...),
+	if len(attrs) > 0 && t.AttributeMapper != nil {
+		attrs = t.AttributeMapper(attrs)
 	}
 
-	return q.tracer().Start(ctx, name, options...)
+	if len(attrs) == 0 {
+		span.AddEvent(name)
+		return
+	}
+
+	t.truncate(attrs)
+	span.AddEvent(name, trace.WithAttributes(attrs...))
 }
 
-func (t *QueryTracer) stop(span trace.Span, err error, attrs []attribute.KeyValue) {
-	defer span.End()
-	// set the attributes
+// annotate sets the valid attributes on the span, rewritten by the AttributeMapper.
+func (t *QueryTracer) annotate(span trace.Span, attrs ...attribute.KeyValue) {
+	if t.Minimal {
+		return
+	}
+
+	valid := attrs[:0]
 	for _, attr := range attrs {
 		if attr.Valid() {
-			span.SetAttributes(attr)
+			valid = append(valid, attr)
+		}
+	}
+
+	valid = stabilize(t.compatible(valid))
+	if t.AttributeMapper != nil {
+		valid = t.AttributeMapper(valid)
+	}
+
+	t.truncate(valid)
+
+	span.SetAttributes(valid...)
+}
+
+func (q *QueryTracer) start(ctx context.Context, name string, attrs []attribute.KeyValue, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if hinted(name) {
+		h := q.hint(name)
+		if name = h.query; h.name != "" {
+			name = h.name
+		}
+		attrs = append(attrs, h.attrs...)
+	}
+
+	if comment, ok := commentName(name); ok {
+		name = comment
+	}
+
+	if q.SpanNamePrefix != "" {
+		name = q.SpanNamePrefix + name
+	}
+
+	if q.Minimal {
+		attrs = append(attrs[:0], semconv.DBSystemPostgreSQL)
+	} else {
+		attrs = append(attrs, q.Attributes...)
+		attrs = append(attrs, q.tenant(ctx)...)
+		attrs = append(attrs, q.request(ctx)...)
+		attrs = append(attrs, q.poolName()...)
+	}
+
+	attrs = stabilize(q.compatible(attrs))
+	if q.AttributeMapper != nil {
+		attrs = q.AttributeMapper(attrs)
+	}
+
+	q.truncate(attrs)
+
+	if q.deferred() {
+		// the attributes are reused once the span started
+		attrs = slices.Clone(attrs)
+	}
+
+	options := make([]trace.SpanStartOption, 0, 2+len(opts))
+	options = append(options, client)
+	options = append(options, trace.WithAttributes(attrs...))
+	options = append(options, opts...)
+
+	if q.deferred() {
+		span := &deferredSpan{
+			tracer:  q,
+			parent:  ctx,
+			name:    name,
+			options: options,
+			start:   q.clock().Now(),
+		}
+		// the start of the recorded operations is provided
+		if len(opts) > 0 {
+			config := trace.NewSpanStartConfig(opts...)
+			if start := config.Timestamp(); !start.IsZero() {
+				span.start = start
+			}
 		}
+
+		q.own(span)
+		return trace.ContextWithSpan(ctx, span), span
+	}
+
+	ctx, span := q.tracer().Start(ctx, name, options...)
+	q.own(span)
+	// done!
+	return ctx, span
+}
+
+func (t *QueryTracer) tenant(ctx context.Context) []attribute.KeyValue {
+	if t.TenantFunc == nil {
+		return nil
+	}
+
+	if tenant := t.TenantFunc(ctx); tenant != "" {
+		return []attribute.KeyValue{TenantKey.String(tenant)}
+	}
+
+	return nil
+}
+
+func (t *QueryTracer) poolName() []attribute.KeyValue {
+	if t.PoolName == "" {
+		return nil
+	}
+
+	return []attribute.KeyValue{PoolNameKey.String(t.PoolName)}
+}
+
+func (t *QueryTracer) request(ctx context.Context) []attribute.KeyValue {
+	if t.RequestIDFunc == nil {
+		return nil
+	}
+
+	if id := t.RequestIDFunc(ctx); id != "" {
+		return []attribute.KeyValue{RequestIDKey.String(id)}
+	}
+
+	return nil
+}
+
+func (t *QueryTracer) stop(ctx context.Context, span trace.Span, tag pgconn.CommandTag, err error, attrs []attribute.KeyValue) {
+	defer span.End()
+	defer t.release(span)
+
+	code, description, cancel := t.finish(ctx, span, tag, err)
+	// nothing else to do for unsampled operations
+	if !span.IsRecording() {
+		return
+	}
+
+	t.annotate(span, attrs...)
+
+	switch {
+	case code == codes.Error && err != nil:
+		t.fail(span, err)
+		if description == "" {
+			description = err.Error()
+		}
+	case cancel:
+		for _, cause := range causes(err) {
+			t.exception(span, cause)
+		}
+		t.annotate(span, CancelledKey.Bool(true))
+	}
+
+	description, _ = t.clip(description)
+	span.SetStatus(code, description)
+}
+
+// finish determines the status of an operation that ended with err and records
+// the error metric. It reports whether the error is a cancellation that is
+// recorded with the db.cancelled attribute.
+func (t *QueryTracer) finish(ctx context.Context, span trace.Span, tag pgconn.CommandTag, err error) (codes.Code, string, bool) {
+	t.end(ctx, span, tag, err)
+
+	code, description, cancel := t.status(ctx, tag, err)
+	if code == codes.Error && err != nil {
+		// record the metric
+		t.recordError(ctx, err)
+	}
+
+	if isCode(err, deadlockDetected) {
+		t.recordDeadlock(ctx)
+	}
+
+	if isCode(err, serializationFailure) {
+		t.recordSerializationFailure(ctx)
+	}
+
+	return code, description, cancel
+}
+
+func (t *QueryTracer) status(ctx context.Context, tag pgconn.CommandTag, err error) (codes.Code, string, bool) {
+	if t.StatusMapper != nil {
+		code, description := t.StatusMapper(err, tag)
+		return code, description, false
 	}
 
 	if err != nil {
-		if !errors.Is(err, sql.ErrNoRows) {
-			if !errors.Is(err, pgx.ErrNoRows) {
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
+		cancel := false
+		// the most severe of the joined errors determines the status
+		for _, cause := range causes(err) {
+			switch {
+			case t.benign(cause):
+			case t.Cancellation == CancellationError || !cancelled(ctx, cause):
+				return codes.Error, "", false
+			case t.Cancellation == CancellationAttribute:
+				cancel = true
+			}
+		}
+
+		if cancel {
+			return codes.Unset, "", true
+		}
+	}
+
+	if t.OkStatus {
+		return codes.Ok, "", false
+	}
+
+	return codes.Unset, "", false
+}
+
+// fail records the errors of a failed operation, each of the joined errors
+// separately.
+func (t *QueryTracer) fail(span trace.Span, err error) {
+	for _, cause := range causes(err) {
+		t.failure(span, cause)
+	}
+}
+
+// failure records an error of a failed operation.
+func (t *QueryTracer) failure(span trace.Span, err error) {
+	t.exception(span, err)
+
+	var perr *pgconn.PgError
+	if !errors.As(err, &perr) {
+		return
+	}
+
+	if t.OtelpgxCompatible {
+		t.annotate(span, SQLStateKey.String(perr.Code))
+	}
+
+	if t.PgErrorEvent {
+		attrs := []attribute.KeyValue{}
+		attrs = append(attrs, PgErrorCodeKey.String(perr.Code))
+		attrs = append(attrs, PgErrorDetailKey.String(t.redact("detail", perr.Detail)))
+		attrs = append(attrs, PgErrorHintKey.String(t.redact("hint", perr.Hint)))
+		attrs = append(attrs, PgErrorWhereKey.String(t.redact("where", perr.Where)))
+		attrs = append(attrs, PgErrorPositionKey.Int(int(perr.Position)))
+
+		t.event(span, "PgError", attrs...)
+	}
+
+	switch perr.Code {
+	case deadlockDetected:
+		attrs := []attribute.KeyValue{}
+		attrs = append(attrs, PgErrorDetailKey.String(t.redact("detail", perr.Detail)))
+
+		t.annotate(span, DeadlockKey.Bool(true))
+		t.event(span, "Deadlock", attrs...)
+	case serializationFailure:
+		t.annotate(span, SerializationFailureKey.Bool(true))
+	}
+}
+
+// redact rewrites the field of a PgError with the PgErrorRedactor.
+func (t *QueryTracer) redact(name, value string) string {
+	if t.PgErrorRedactor != nil && value != "" {
+		return t.PgErrorRedactor(name, value)
+	}
+
+	return value
+}
+
+// benign reports whether the error ends a span without the Error status.
+func (t *QueryTracer) benign(err error) bool {
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return true
+	}
+
+	for _, target := range t.BenignErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	if len(t.BenignCodes) > 0 {
+		var perr *pgconn.PgError
+		if errors.As(err, &perr) {
+			return slices.Contains(t.BenignCodes, perr.Code)
+		}
+	}
+
+	return false
+}
+
+// causes returns the distinct errors joined by err (see errors.Join), or err
+// itself when it does not join several errors. The message of the errors that
+// wrap the joined errors (e.g. fmt.Errorf("rollback: %w", errors.Join(a, b))) is
+// kept on the first cause.
+func causes(err error) []error {
+	for cause := err; cause != nil; cause = errors.Unwrap(cause) {
+		joined, ok := cause.(interface{ Unwrap() []error })
+		if !ok {
+			continue
+		}
+
+		errs := []error{}
+		for _, inner := range joined.Unwrap() {
+			if inner == nil {
+				continue
+			}
+
+			for _, leaf := range causes(inner) {
+				// the same error might be joined several times
+				if !slices.ContainsFunc(errs, func(e error) bool { return same(e, leaf) }) {
+					errs = append(errs, leaf)
+				}
+			}
+		}
+
+		if len(errs) == 0 {
+			continue
+		}
+
+		if cause != err {
+			message := err.Error()
+			// the wrapper usually prefixes the message of the joined errors
+			if prefix, ok := strings.CutSuffix(message, cause.Error()); ok {
+				message = prefix + errs[0].Error()
 			}
+
+			errs[0] = &wrappedError{message: message, err: errs[0]}
 		}
+
+		return errs
+	}
+
+	return []error{err}
+}
+
+// same reports whether the errors are the same error value.
+func same(err, other error) bool {
+	if reflect.TypeOf(err) != reflect.TypeOf(other) {
+		return false
+	}
+	// the errors that cannot be compared are never the same
+	return reflect.ValueOf(err).Comparable() && err == other
+}
+
+// wrappedError is a joined error with the message of the errors that wrap it.
+type wrappedError struct {
+	message string
+	err     error
+}
+
+// Error implements error.
+func (w *wrappedError) Error() string {
+	return w.message
+}
+
+// Unwrap returns the joined error.
+func (w *wrappedError) Unwrap() error {
+	return w.err
+}
+
+// cancelled reports whether the error was caused by the cancellation of the context.
+func cancelled(ctx context.Context, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
 	}
+
+	// the server cancels the query when the context is done
+	var perr *pgconn.PgError
+	if errors.As(err, &perr) && perr.Code == "57014" {
+		return ctx.Err() != nil
+	}
+
+	return false
+}
+
+// ConnAttributes returns the db.* attributes a tracer with the default options
+// records on the spans of the connections of the config (see
+// QueryTracer.ConnAttributes).
+func ConnAttributes(config *pgx.ConnConfig) []attribute.KeyValue {
+	return (&QueryTracer{}).ConnAttributes(config)
+}
+
+// ConnAttributes returns the db.* attributes the tracer records on the spans of
+// the connections of the config, so that other spans can be tagged with the same
+// identity. The attributes discovered once connected are not included.
+func (t *QueryTracer) ConnAttributes(config *pgx.ConnConfig) []attribute.KeyValue {
+	return stabilize(t.compatible(t.config(config)))
 }
 
 func (t *QueryTracer) config(config *pgx.ConnConfig) []attribute.KeyValue {
-	return []attribute.KeyValue{
-		semconv.DBSystemPostgreSQL,
-		semconv.DBUser(config.User),
-		semconv.DBName(config.Database),
-		semconv.DBConnectionString(t.connection(config)),
+	attrs := make([]attribute.KeyValue, 0, 4+len(t.RuntimeParams))
+	attrs = append(attrs, semconv.DBSystemPostgreSQL)
+	attrs = append(attrs, semconv.DBUser(t.user(config)))
+	attrs = append(attrs, semconv.DBName(config.Database))
+
+	if !t.OmitConnectionString {
+		attrs = append(attrs, semconv.DBConnectionString(t.connection(config)))
 	}
+
+	for _, name := range t.RuntimeParams {
+		if value, ok := config.RuntimeParams[name]; ok {
+			attrs = append(attrs, attribute.String(RuntimeParamKeyPrefix+name, value))
+		}
+	}
+
+	attrs = append(attrs, t.pooler(config)...)
+
+	return attrs
 }
 
 func (t *QueryTracer) connection(config *pgx.ConnConfig) string {
-	conn := config.ConnString()
-	conn = strings.ReplaceAll(conn, config.Password, strings.Repeat("*", len(config.Password)))
-	return conn
+	// the connection strings are rebuilt once
+	return t.connections.get(config.ConnString(), func(conn string) string {
+		return t.redactConnection(conn, config)
+	})
 }
 
-func (q *QueryTracer) command(command pgconn.CommandTag) attribute.KeyValue {
-	name := "UNKNOWN"
-
-	switch {
-	case command.Select():
-		name = "SELECT"
-	case command.Insert():
-		name = "INSERT"
-	case command.Delete():
-		name = "DELETE"
-	case command.Update():
-		name = "UPDATE"
+// redactConnection rebuilds the connection string with the redacted user and password.
+func (t *QueryTracer) redactConnection(conn string, config *pgx.ConnConfig) string {
+	password := strings.Repeat("*", len(config.Password))
+	// the user is rewritten in the connection string as well
+	if strings.HasPrefix(conn, "postgres://") || strings.HasPrefix(conn, "postgresql://") {
+		return t.connectionURL(conn, config, password)
 	}
 
-	return semconv.DBOperation(name)
+	return t.connectionKeywords(conn, config, password)
 }
 
-func (t *QueryTracer) collection(name pgx.Identifier) attribute.KeyValue {
-	return semconv.DBSQLTable(name.Sanitize())
+// connectionURL rebuilds the user information of the URL connection string.
+func (t *QueryTracer) connectionURL(conn string, config *pgx.ConnConfig, password string) string {
+	u, err := url.Parse(conn)
+	if err != nil {
+		// pgx parsed the connection string already
+		return ""
+	}
+
+	if u.User == nil {
+		return conn
+	}
+
+	user := url.User(t.user(config)).String()
+	if _, ok := u.User.Password(); ok {
+		user += ":" + password
+	}
+
+	u.User = nil
+	scheme, rest, _ := strings.Cut(u.String(), "://")
+	// done!
+	return scheme + "://" + user + "@" + rest
 }
 
-func (q *QueryTracer) statement(query string) attribute.KeyValue {
-	reader := strings.NewReader(query)
-	scanner := bufio.NewScanner(reader)
+// connectionKeywords rebuilds the user and password values of the keyword/value
+// connection string.
+func (t *QueryTracer) connectionKeywords(conn string, config *pgx.ConnConfig, password string) string {
+	pairs := []string{}
+
+	for {
+		conn = strings.TrimLeftFunc(conn, unicode.IsSpace)
+		if conn == "" {
+			break
+		}
+
+		key, rest, ok := strings.Cut(conn, "=")
+		if !ok {
+			// pgx parsed the connection string already
+			return ""
+		}
+
+		value, rest := keywordValue(strings.TrimLeftFunc(rest, unicode.IsSpace))
+		conn = rest
+
+		switch key = strings.TrimSpace(key); key {
+		case "user":
+			value = t.user(config)
+		case "password":
+			value = password
+		}
+
+		pairs = append(pairs, key+"="+keywordQuote(value))
+	}
+
+	return strings.Join(pairs, " ")
+}
 
+// keywordValue returns the value at the start of the keyword/value connection
+// string, unquoted, and the rest of it.
+func keywordValue(conn string) (string, string) {
 	builder := &strings.Builder{}
-	// scan the query and fill the builder
-	for scanner.Scan() {
-		text := scanner.Text()
-		text = strings.TrimSpace(text)
 
-		index := strings.Index(text, "--")
+	if rest, ok := strings.CutPrefix(conn, "'"); ok {
+		for index := 0; index < len(rest); index++ {
+			switch c := rest[index]; {
+			case c == '\\' && index+1 < len(rest):
+				index++
+				builder.WriteByte(rest[index])
+			case c == '\'':
+				return builder.String(), rest[index+1:]
+			default:
+				builder.WriteByte(c)
+			}
+		}
+
+		return builder.String(), ""
+	}
 
-		if index == 0 {
-			continue
+	for index := 0; index < len(conn); index++ {
+		switch c := conn[index]; {
+		case c == '\\' && index+1 < len(conn):
+			index++
+			builder.WriteByte(conn[index])
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			return builder.String(), conn[index:]
+		default:
+			builder.WriteByte(c)
 		}
+	}
+
+	return builder.String(), ""
+}
 
-		if index > 0 {
-			text = text[:index]
+// keywordQuote quotes the value of a keyword/value connection string if needed.
+func keywordQuote(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\r'\\") {
+		return value
+	}
+
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	// done!
+	return "'" + value + "'"
+}
+
+func (t *QueryTracer) user(config *pgx.ConnConfig) string {
+	if t.UserRedactor != nil {
+		return t.UserRedactor(config.User)
+	}
+
+	return config.User
+}
+
+func (t *QueryTracer) hosts(config *pgx.ConnConfig) []attribute.KeyValue {
+	if t.Minimal || len(config.Fallbacks) == 0 {
+		return nil
+	}
+
+	hosts := make([]string, 0, len(config.Fallbacks)+1)
+	hosts = append(hosts, net.JoinHostPort(config.Host, strconv.Itoa(int(config.Port))))
+
+	for _, fallback := range config.Fallbacks {
+		address := net.JoinHostPort(fallback.Host, strconv.Itoa(int(fallback.Port)))
+		// the same host might be configured with and without TLS
+		if !slices.Contains(hosts, address) {
+			hosts = append(hosts, address)
 		}
+	}
+
+	return []attribute.KeyValue{
+		HostsKey.StringSlice(hosts),
+	}
+}
 
-		text = strings.TrimSpace(text)
+func (t *QueryTracer) peer(conn *pgconn.PgConn) []attribute.KeyValue {
+	if t.Minimal {
+		return nil
+	}
 
-		if builder.Len() > 0 {
-			builder.WriteString(" ")
+	address := conn.Conn().RemoteAddr().String()
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		// unix domain sockets do not have a port
+		return []attribute.KeyValue{
+			semconv.NetSockPeerAddr(address),
 		}
+	}
 
-		builder.WriteString(text)
+	attrs := []attribute.KeyValue{}
+	attrs = append(attrs, semconv.NetSockPeerAddr(host))
+	if value, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, semconv.NetSockPeerPort(value))
 	}
 
-	statement := builder.String()
-	// done
-	return semconv.DBStatement(statement)
+	return attrs
+}
+
+// server returns the address and the role of the server the connection landed on.
+func (t *QueryTracer) server(conn *pgx.Conn) []attribute.KeyValue {
+	if t.Minimal {
+		return nil
+	}
+
+	attrs := []attribute.KeyValue{}
+	if address, ok := t.dialed(conn); ok {
+		attrs = append(attrs, HostKey.String(address))
+	}
+	// in_hot_standby is reported by PostgreSQL 14 and later
+	switch conn.PgConn().ParameterStatus("in_hot_standby") {
+	case "on":
+		attrs = append(attrs, ServerRoleKey.String("standby"))
+	case "off":
+		attrs = append(attrs, ServerRoleKey.String("primary"))
+	}
+
+	return attrs
+}
+
+// dialed returns the configured host and port the connection landed on: the one
+// recorded by InstrumentConnect, or the only configured one.
+func (t *QueryTracer) dialed(conn *pgx.Conn) (string, bool) {
+	if address, ok := dialedAddress(conn.PgConn().Conn()); ok {
+		return address, true
+	}
+
+	config := t.cache(conn).config
+	if len(config.Fallbacks) > 0 {
+		return "", false
+	}
+
+	return net.JoinHostPort(config.Host, strconv.Itoa(int(config.Port))), true
+}
+
+// serverVersion returns the version of the server the connection landed on.
+func (t *QueryTracer) serverVersion(conn *pgconn.PgConn) []attribute.KeyValue {
+	if t.Minimal {
+		return nil
+	}
+
+	if version := conn.ParameterStatus("server_version"); version != "" {
+		return []attribute.KeyValue{ServerVersionKey.String(version)}
+	}
+
+	return nil
+}
+
+// table returns the table attribute of the query, if the table is known.
+func (t *QueryTracer) table(query string) []attribute.KeyValue {
+	if t.Minimal {
+		return nil
+	}
+
+	if name := t.tables.get(query, tableName); name != "" {
+		return []attribute.KeyValue{semconv.DBSQLTable(name)}
+	}
+
+	return nil
+}
+
+func (q *QueryTracer) command(command pgconn.CommandTag) attribute.KeyValue {
+	name := "UNKNOWN"
+
+	switch {
+	case command.Select():
+		name = "SELECT"
+	case command.Insert():
+		name = "INSERT"
+	case command.Delete():
+		name = "DELETE"
+	case command.Update():
+		name = "UPDATE"
+	}
+
+	return semconv.DBOperation(name)
+}
+
+func (t *QueryTracer) collection(name pgx.Identifier) attribute.KeyValue {
+	return semconv.DBSQLTable(name.Sanitize())
 }
@@ -0,0 +1,214 @@
+package pgxotel
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	pgx "github.com/jackc/pgx/v5"
+	pgconn "github.com/jackc/pgx/v5/pgconn"
+	attribute "go.opentelemetry.io/otel/attribute"
+	propagation "go.opentelemetry.io/otel/propagation"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// IsolationLevelKey is the attribute key for the isolation level of a transaction.
+	IsolationLevelKey = attribute.Key("db.postgresql.isolation_level")
+	// TxOutcomeKey is the attribute key for the outcome (commit or rollback) of a transaction.
+	TxOutcomeKey = attribute.Key("db.postgresql.tx_outcome")
+	// SavepointKey is the attribute key for the name of a savepoint.
+	SavepointKey = attribute.Key("db.postgresql.savepoint")
+)
+
+// Beginner is the interface implemented by pgx.Conn, pgxpool.Conn and pgxpool.Pool.
+type Beginner interface {
+	BeginTx(ctx context.Context, options pgx.TxOptions) (pgx.Tx, error)
+}
+
+// BeginTx starts a transaction traced by a Transaction span that ends when the
+// transaction is committed or rolled back. The returned context carries the span
+// and should be used for the statements of the transaction. The savepoints created
+// by the Begin method of the transaction are traced by Savepoint spans.
+func (t *QueryTracer) BeginTx(ctx context.Context, db Beginner, options pgx.TxOptions) (context.Context, pgx.Tx, error) {
+	if !trace.SpanFromContext(ctx).IsRecording() || t.disabled(OperationTransaction) {
+		tx, err := db.BeginTx(ctx, options)
+		return ctx, tx, err
+	}
+
+	attrs := []attribute.KeyValue{}
+	if options.IsoLevel != "" {
+		attrs = append(attrs, IsolationLevelKey.String(strings.ToLower(string(options.IsoLevel))))
+	}
+
+	// prepare the span
+	ctx, span := t.start(ctx, "Transaction", attrs, t.SpanStartOptions[OperationTransaction]...)
+
+	tx, err := db.BeginTx(ctx, options)
+	if err != nil {
+		t.stop(ctx, span, pgconn.CommandTag{}, err, nil)
+		return ctx, nil, err
+	}
+
+	attrs = append(attrs[:0], t.cache(tx.Conn()).attrs...)
+	attrs = append(attrs, t.instance(tx.Conn())...)
+	t.annotate(span, attrs...)
+
+	if err := t.propagate(ctx, tx); err != nil {
+		// the transaction is unusable
+		_ = tx.Rollback(ctx)
+		t.stop(ctx, span, pgconn.CommandTag{}, err, nil)
+		return ctx, nil, err
+	}
+
+	return ctx, &tracedTx{Tx: tx, tracer: t, span: span, savepoints: new(int)}, nil
+}
+
+// propagate sets the TraceParentParameter of the transaction to the W3C
+// traceparent of ctx.
+func (t *QueryTracer) propagate(ctx context.Context, tx pgx.Tx) error {
+	if t.TraceParentParameter == "" {
+		return nil
+	}
+
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	// the setting is local to the transaction
+	_, err := tx.Exec(untraced(ctx), "SELECT set_config($1, $2, true)", t.TraceParentParameter, carrier.Get("traceparent"))
+	return err
+}
+
+type tracedTx struct {
+	pgx.Tx
+	tracer     *QueryTracer
+	span       trace.Span
+	savepoints *int
+}
+
+// Begin implements pgx.Tx. The savepoint is traced by a Savepoint span parented
+// under the transaction span.
+func (tx *tracedTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	if tx.span == nil {
+		return tx.Tx.Begin(ctx)
+	}
+
+	return tx.tracer.savepoint(ctx, tx.Tx, tx.span, tx.savepoints)
+}
+
+// Commit implements pgx.Tx.
+func (tx *tracedTx) Commit(ctx context.Context) error {
+	err := tx.Tx.Commit(ctx)
+	tx.end(ctx, "commit", err)
+	return err
+}
+
+// Rollback implements pgx.Tx.
+func (tx *tracedTx) Rollback(ctx context.Context) error {
+	err := tx.Tx.Rollback(ctx)
+	tx.end(ctx, "rollback", err)
+	return err
+}
+
+func (tx *tracedTx) end(ctx context.Context, outcome string, err error) {
+	// rollback is usually deferred after commit
+	if tx.span == nil {
+		return
+	}
+
+	attrs := []attribute.KeyValue{}
+	attrs = append(attrs, TxOutcomeKey.String(outcome))
+	// done!
+	tx.tracer.stop(ctx, tx.span, pgconn.CommandTag{}, err, attrs)
+	tx.span = nil
+}
+
+// savepoint creates a savepoint on tx traced by a Savepoint span that is a child
+// of parent, if any. The savepoints of a transaction are numbered by count.
+func (t *QueryTracer) savepoint(ctx context.Context, tx pgx.Tx, parent trace.Span, count *int) (pgx.Tx, error) {
+	*count++
+	// the savepoints are named like the ones of pgx
+	name := "sp_" + strconv.Itoa(*count)
+
+	sp := &tracedSavepoint{Tx: tx, tracer: t, name: name, savepoints: count}
+	// prepare the span
+	if parent != nil && parent.IsRecording() {
+		attrs := []attribute.KeyValue{}
+		attrs = append(attrs, SavepointKey.String(name))
+
+		ctx = trace.ContextWithSpan(ctx, parent)
+		ctx, sp.span = t.start(ctx, "Savepoint", attrs, t.SpanStartOptions[OperationTransaction]...)
+	}
+
+	if _, err := tx.Exec(ctx, "SAVEPOINT "+name); err != nil {
+		sp.end(ctx, "", err)
+		return nil, err
+	}
+
+	return sp, nil
+}
+
+// tracedSavepoint is a pseudo nested transaction traced by a Savepoint span.
+type tracedSavepoint struct {
+	pgx.Tx
+	tracer     *QueryTracer
+	span       trace.Span
+	name       string
+	savepoints *int
+	closed     bool
+}
+
+// Begin implements pgx.Tx.
+func (sp *tracedSavepoint) Begin(ctx context.Context) (pgx.Tx, error) {
+	if sp.closed {
+		return nil, pgx.ErrTxClosed
+	}
+
+	return sp.tracer.savepoint(ctx, sp.Tx, sp.span, sp.savepoints)
+}
+
+// Commit implements pgx.Tx. It releases the savepoint.
+func (sp *tracedSavepoint) Commit(ctx context.Context) error {
+	if sp.closed {
+		return pgx.ErrTxClosed
+	}
+
+	_, err := sp.Exec(sp.context(ctx), "RELEASE SAVEPOINT "+sp.name)
+	sp.end(ctx, "release", err)
+	return err
+}
+
+// Rollback implements pgx.Tx. It rolls back to the savepoint.
+func (sp *tracedSavepoint) Rollback(ctx context.Context) error {
+	if sp.closed {
+		return pgx.ErrTxClosed
+	}
+
+	_, err := sp.Exec(sp.context(ctx), "ROLLBACK TO SAVEPOINT "+sp.name)
+	sp.end(ctx, "rollback", err)
+	return err
+}
+
+// context returns ctx with the span of the savepoint.
+func (sp *tracedSavepoint) context(ctx context.Context) context.Context {
+	if sp.span == nil {
+		return ctx
+	}
+
+	return trace.ContextWithSpan(ctx, sp.span)
+}
+
+func (sp *tracedSavepoint) end(ctx context.Context, outcome string, err error) {
+	sp.closed = true
+
+	if sp.span == nil {
+		return
+	}
+
+	attrs := []attribute.KeyValue{}
+	if outcome != "" {
+		attrs = append(attrs, TxOutcomeKey.String(outcome))
+	}
+	// done!
+	sp.tracer.stop(ctx, sp.span, pgconn.CommandTag{}, err, attrs)
+	sp.span = nil
+}
@@ -0,0 +1,204 @@
+package pgxotel_test
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pgx-contrib/pgxotel"
+	"github.com/pgx-contrib/pgxotel/internal/fakepg"
+	"github.com/pgx-contrib/pgxotel/pgxoteltest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func ExampleQueryTracer_BeginTx() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PGX_DATABASE_URL"))
+	if err != nil {
+		panic(err)
+	}
+
+	tracer := pgxotel.Configure(config,
+		pgxotel.WithTraceParentParameter("otel.traceparent"),
+	)
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), config)
+	if err != nil {
+		panic(err)
+	}
+	// close the pool
+	defer pool.Close()
+
+	ctx, tx, err := tracer.BeginTx(context.TODO(), pool, pgx.TxOptions{})
+	if err != nil {
+		panic(err)
+	}
+	// rollback the transaction on error
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "UPDATE customer SET visits = visits + 1"); err != nil {
+		panic(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		panic(err)
+	}
+}
+
+func TestQueryTracer_BeginTx(t *testing.T) {
+	recorder := pgxoteltest.NewRecorder()
+	tracer := pgxotel.NewQueryTracer("example-api", pgxotel.WithTraceParentParameter("otel.traceparent"), recorder.Option())
+
+	server := &fakepg.Server{
+		Handler: func(sql string, _ []string) fakepg.Result {
+			if sql == "SELECT set_config($1, $2, true)" {
+				return fakepg.Result{Columns: []string{"set_config"}}
+			}
+
+			return fakepg.Result{}
+		},
+	}
+
+	ctx, span := recorder.Start(context.TODO(), "test")
+	conn := connect(t, ctx, server, tracer)
+
+	ctx, tx, err := tracer.BeginTx(ctx, conn, pgx.TxOptions{IsoLevel: pgx.Serializable})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE customer SET visits = visits + 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	span.End()
+
+	spans := recorder.Spans()
+	transaction := lookup(t, spans, "Transaction")
+	if transaction.Parent.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("expected the Transaction span to be a child of the test span")
+	}
+
+	for key, expected := range map[attribute.Key]string{pgxotel.IsolationLevelKey: "serializable", pgxotel.TxOutcomeKey: "commit"} {
+		if value, _ := transaction.Attribute(key); value.AsString() != expected {
+			t.Errorf("expected the %s attribute %q of the Transaction span, got %q", key, expected, value.AsString())
+		}
+	}
+
+	queries := server.Queries()
+	// the traceparent is set right after the transaction begins
+	expected := []string{"begin isolation level serializable", "SELECT set_config($1, $2, true)", "UPDATE customer SET visits = visits + 1", "commit"}
+	if statements := statementsOf(queries); !slices.Equal(statements, expected) {
+		t.Fatalf("expected the queries %q, got %q", expected, statements)
+	}
+
+	traceparent := "00-" + transaction.SpanContext.TraceID().String() + "-" + transaction.SpanContext.SpanID().String() + "-01"
+	if args := queries[1].Args; !slices.Equal(args, []string{"otel.traceparent", traceparent}) {
+		t.Errorf("expected the traceparent of the Transaction span, got %q", args)
+	}
+	// the propagation is not traced
+	pgxoteltest.AssertQueryCount(t, spans, 0, pgxoteltest.WithStatement("SELECT set_config($1, $2, true)"))
+}
+
+// statementsOf returns the statements of the queries.
+func statementsOf(queries []fakepg.Query) []string {
+	statements := []string{}
+	for _, query := range queries {
+		statements = append(statements, query.SQL)
+	}
+
+	return statements
+}
+
+func TestQueryTracer_BeginTx_savepoints(t *testing.T) {
+	recorder := pgxoteltest.NewRecorder()
+	tracer := pgxotel.NewQueryTracer("example-api", recorder.Option())
+
+	server := &fakepg.Server{}
+
+	ctx, span := recorder.Start(context.TODO(), "test")
+	conn := connect(t, ctx, server, tracer)
+
+	ctx, tx, err := tracer.BeginTx(ctx, conn, pgx.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outer, err := tx.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inner, err := outer.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := inner.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := outer.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	span.End()
+
+	expected := []string{"begin", "SAVEPOINT sp_1", "SAVEPOINT sp_2", "ROLLBACK TO SAVEPOINT sp_2", "RELEASE SAVEPOINT sp_1", "commit"}
+	if statements := statementsOf(server.Queries()); !slices.Equal(statements, expected) {
+		t.Fatalf("expected the queries %q, got %q", expected, statements)
+	}
+
+	spans := recorder.Spans()
+	transaction := lookup(t, spans, "Transaction")
+
+	savepoints := map[string]pgxoteltest.Span{}
+	for _, savepoint := range named(spans, "Savepoint") {
+		name, _ := savepoint.Attribute(pgxotel.SavepointKey)
+		savepoints[name.AsString()] = savepoint
+	}
+
+	cases := []struct {
+		name    string
+		parent  trace.SpanID
+		outcome string
+		queries []string
+	}{
+		{"sp_1", transaction.SpanContext.SpanID(), "release", []string{"SAVEPOINT sp_1", "RELEASE SAVEPOINT sp_1"}},
+		{"sp_2", savepoints["sp_1"].SpanContext.SpanID(), "rollback", []string{"SAVEPOINT sp_2", "ROLLBACK TO SAVEPOINT sp_2"}},
+	}
+
+	for _, c := range cases {
+		savepoint, ok := savepoints[c.name]
+		if !ok {
+			t.Errorf("expected the Savepoint span of %s", c.name)
+			continue
+		}
+		// the savepoints are nested
+		if savepoint.Parent.SpanID() != c.parent {
+			t.Errorf("expected the Savepoint span of %s to be nested in its enclosing transaction", c.name)
+		}
+
+		if value, _ := savepoint.Attribute(pgxotel.TxOutcomeKey); value.AsString() != c.outcome {
+			t.Errorf("expected the outcome %q of %s, got %q", c.outcome, c.name, value.AsString())
+		}
+
+		for _, sql := range c.queries {
+			query := pgxoteltest.AssertQuerySpan(t, spans, pgxoteltest.WithStatement(sql), pgxoteltest.WithName(sql))
+			if query.Parent.SpanID() != savepoint.SpanContext.SpanID() {
+				t.Errorf("expected the %q span to be a child of the Savepoint span of %s", sql, c.name)
+			}
+		}
+	}
+}
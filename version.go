@@ -0,0 +1,64 @@
+package pgxotel
+
+import (
+	"runtime/debug"
+	"sync"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	stable "go.opentelemetry.io/otel/semconv/v1.26.0"
+	trace "go.opentelemetry.io/otel/trace"
+)
+
+// moduleVersion returns the version of the module from the build information.
+var moduleVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	if info.Main.Path == instrumentation {
+		return info.Main.Version
+	}
+
+	for _, module := range info.Deps {
+		if module.Path == instrumentation {
+			if module.Replace != nil {
+				return module.Replace.Version
+			}
+
+			return module.Version
+		}
+	}
+
+	return ""
+})
+
+// tracerOptions returns the options of the tracer, including the instrumentation
+// version and schema URL. The options are computed once.
+func (t *QueryTracer) tracerOptions() []trace.TracerOption {
+	t.setup.Do(func() {
+		version := t.Version
+		if version == "" {
+			version = moduleVersion()
+		}
+
+		schema := t.SchemaURL
+		if schema == "" {
+			schema = semconv.SchemaURL
+			// the stable conventions have their own schema
+			if semconvStability() == stabilityNew {
+				schema = stable.SchemaURL
+			}
+		}
+
+		t.options = make([]trace.TracerOption, 0, 2+len(t.Options))
+		if version != "" && version != "(devel)" {
+			t.options = append(t.options, trace.WithInstrumentationVersion(version))
+		}
+		t.options = append(t.options, trace.WithSchemaURL(schema))
+		// the options of the user take precedence
+		t.options = append(t.options, t.Options...)
+	})
+
+	return t.options
+}